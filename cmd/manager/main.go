@@ -26,9 +26,14 @@ import (
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
 	"github.com/platform9/fluentd-operator/pkg/apis"
 	"github.com/platform9/fluentd-operator/pkg/controller"
+	"github.com/platform9/fluentd-operator/pkg/metrics"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/pkg/utils"
 
 	//flag "github.com/spf13/pflag"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -37,6 +42,8 @@ import (
 
 var log = logf.Log.WithName("cmd")
 
+var dumpConfig = flag.String("dump-config", "", "Write embedded default configuration to this directory and exit")
+
 func printVersion() {
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
 	log.Info(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
@@ -44,7 +51,8 @@ func printVersion() {
 }
 
 func main() {
-
+	opts := options.New()
+	opts.AddFlags(flag.CommandLine)
 	flag.Parse()
 
 	// The logger instantiated here can be changed to any logger
@@ -55,6 +63,20 @@ func main() {
 
 	printVersion()
 
+	if len(*dumpConfig) > 0 {
+		if err := utils.DumpDefaultCfg(*dumpConfig); err != nil {
+			log.Error(err, "failed to dump default configuration")
+			os.Exit(1)
+		}
+		log.Info("Wrote default configuration", "dir", *dumpConfig)
+		return
+	}
+
+	if err := utils.ValidateCfgDir(opts.CfgDir); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
 	namespace, err := k8sutil.GetWatchNamespace()
 	if err != nil {
 		log.Error(err, "failed to get watch namespace")
@@ -80,7 +102,10 @@ func main() {
 	defer r.Unset()
 
 	// Create a new Cmd to provide shared dependencies and start components
-	mgr, err := manager.New(cfg, manager.Options{Namespace: namespace})
+	mgr, err := manager.New(cfg, manager.Options{
+		Namespace:          namespace,
+		MetricsBindAddress: fmt.Sprintf("0.0.0.0:%d", opts.MetricsPort),
+	})
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
@@ -95,11 +120,15 @@ func main() {
 	}
 
 	// Setup all Controllers
-	if err := controller.AddToManager(mgr); err != nil {
+	if err := controller.AddToManager(mgr, opts); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
+	if err := exposeMetrics(cfg, mgr, opts.MetricsPort); err != nil {
+		log.Info("Could not create metrics service", "error", err.Error())
+	}
+
 	log.Info("Starting the Cmd.")
 
 	// Start the Cmd
@@ -108,3 +137,28 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// exposeMetrics creates a service for operator metrics when running in a cluster
+func exposeMetrics(cfg *rest.Config, mgr manager.Manager, port int) error {
+	ns, err := k8sutil.GetOperatorNamespace()
+	if err != nil {
+		if err == k8sutil.ErrNoNamespace {
+			log.Info("Not running in a cluster, skipping metrics service")
+			return nil
+		}
+		return err
+	}
+
+	name, err := k8sutil.GetOperatorName()
+	if err != nil {
+		return err
+	}
+
+	// Manager cache is not started yet
+	c, err := client.New(cfg, client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return err
+	}
+
+	return metrics.Expose(c, mgr.GetRESTMapper(), ns, name, int32(port))
+}
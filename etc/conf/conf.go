@@ -0,0 +1,23 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conf embeds default configuration of fluent components into the operator binary. Each component
+// has a directory of files, all of which must be present when defaults are overridden with -cfg-dir.
+package conf
+
+import "embed"
+
+// FS holds default configuration, one directory per component
+//
+//go:embed fluent-bit
+var FS embed.FS
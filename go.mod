@@ -1,6 +1,6 @@
 module github.com/platform9/fluentd-operator
 
-go 1.13
+go 1.16
 
 require (
 	cloud.google.com/go v0.53.0 // indirect
@@ -12,7 +12,7 @@ require (
 	github.com/go-test/deep v1.0.5 // indirect
 	github.com/google/gofuzz v1.1.0 // indirect
 	github.com/gorilla/mux v1.7.4
-	github.com/hashicorp/golang-lru v0.5.4 // indirect
+	github.com/hashicorp/golang-lru v0.5.4
 	github.com/iancoleman/strcase v0.0.0-20191112232945-16388991a334 // indirect
 	github.com/imdario/mergo v0.3.8
 	github.com/konsorten/go-windows-terminal-sequences v1.0.2 // indirect
@@ -20,9 +20,10 @@ require (
 	github.com/mailru/easyjson v0.7.1 // indirect
 	github.com/operator-framework/operator-sdk v0.8.1-0.20190626205328-61b6af18ee50
 	github.com/pelletier/go-toml v1.6.0 // indirect
+	github.com/philhofer/fwd v1.2.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/presslabs/controller-util v0.2.0
-	github.com/prometheus/client_golang v1.4.1 // indirect
+	github.com/prometheus/client_golang v1.4.1
 	github.com/prometheus/procfs v0.0.10 // indirect
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cast v1.3.1 // indirect
@@ -30,6 +31,7 @@ require (
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/viper v1.6.2
 	github.com/stretchr/testify v1.4.0
+	github.com/tinylib/msgp v1.1.2
 	go.uber.org/atomic v1.5.1 // indirect
 	go.uber.org/multierr v1.4.0 // indirect
 	go.uber.org/zap v1.13.0 // indirect
@@ -0,0 +1,77 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LoggingConfigName is the name of the singleton LoggingConfig read by the operator. Objects with other
+// names are ignored.
+const LoggingConfigName = "default"
+
+// LoggingConfigSpec defines the desired state of fluentd and fluent-bit workloads
+type LoggingConfigSpec struct {
+	Fluentd   ComponentSpec `json:"fluentd,omitempty"`
+	Fluentbit ComponentSpec `json:"fluentbit,omitempty"`
+}
+
+// ComponentSpec customizes pods of a fluent component. Fields left empty keep operator defaults.
+type ComponentSpec struct {
+	// Image overrides -fluentd-image or -fluentbit-image
+	Image string `json:"image,omitempty"`
+	// Resources replaces default requests and limits of the container
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Tolerations replace default toleration of master nodes
+	Tolerations       []corev1.Toleration `json:"tolerations,omitempty"`
+	NodeSelector      map[string]string   `json:"nodeSelector,omitempty"`
+	PriorityClassName string              `json:"priorityClassName,omitempty"`
+	// Replicas of fluentd deployment, defaults to 1. Ignored for fluent-bit, which runs on every node.
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Env, Volumes and VolumeMounts are added to the ones managed by operator
+	Env          []corev1.EnvVar      `json:"env,omitempty"`
+	Volumes      []corev1.Volume      `json:"volumes,omitempty"`
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+}
+
+// LoggingConfigStatus defines the observed state of LoggingConfig
+type LoggingConfigStatus struct {
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// LoggingConfig is the Schema for the loggingconfigs API
+type LoggingConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LoggingConfigSpec   `json:"spec,omitempty"`
+	Status LoggingConfigStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// LoggingConfigList contains a list of LoggingConfig
+type LoggingConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LoggingConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LoggingConfig{}, &LoggingConfigList{})
+}
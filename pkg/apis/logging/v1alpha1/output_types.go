@@ -26,6 +26,10 @@ type OutputSpec struct {
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	Type   string  `json:"type"`
 	Params []Param `json:"params,omitempty"`
+	// Namespace scopes the output to container logs of a namespace
+	Namespace string `json:"namespace,omitempty"`
+	// TTL deletes the output once it expires, counted from its creation, e.g. for debugging with a stdout output
+	TTL *metav1.Duration `json:"ttl,omitempty"`
 }
 
 // Param defines a parameter to be passed along with output, such as credentials
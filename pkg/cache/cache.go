@@ -0,0 +1,101 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cache provides an informer cache restricted to the logging namespace. Controllers of fluentd and
+// fluent-bit share it, so that the operator doesn't cache deployments, config maps and secrets of the whole
+// cluster to manage a handful of objects in one namespace.
+package cache
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	crcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Namespaced is an informer cache of a single namespace, along with a client reading from it
+type Namespaced struct {
+	crcache.Cache
+	// Client reads namespaced objects from the cache and writes to the apiserver. Unstructured objects,
+	// such as monitors of prometheus-operator, are read from the apiserver.
+	Client client.Client
+}
+
+type key struct {
+	mgr manager.Manager
+	ns  string
+}
+
+var (
+	mu     sync.Mutex
+	caches = map[key]*Namespaced{}
+)
+
+// Get returns cache of namespace ns, creating it and adding it to mgr on first use
+func Get(mgr manager.Manager, ns string) (*Namespaced, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := key{mgr: mgr, ns: ns}
+	if c, ok := caches[k]; ok {
+		return c, nil
+	}
+
+	informers, err := newInformers(mgr.GetConfig(), mgr.GetScheme(), mgr.GetRESTMapper(), ns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Started along with controllers watching it
+	if err := mgr.Add(informers); err != nil {
+		return nil, err
+	}
+
+	c := &Namespaced{
+		Cache: informers,
+		Client: &client.DelegatingClient{
+			Reader: &client.DelegatingReader{
+				CacheReader:  informers,
+				ClientReader: mgr.GetAPIReader(),
+			},
+			Writer:       mgr.GetClient(),
+			StatusClient: mgr.GetClient(),
+		},
+	}
+	caches[k] = c
+	return c, nil
+}
+
+// newInformers returns informers of namespace ns. Unlike predicates of controllers watching it, which filter
+// events only, the namespace restricts what informers list and keep in memory.
+func newInformers(config *rest.Config, scheme *runtime.Scheme, mapper meta.RESTMapper, ns string) (crcache.Cache, error) {
+	return crcache.New(config, crcache.Options{
+		Scheme:    scheme,
+		Mapper:    mapper,
+		Namespace: ns,
+	})
+}
+
+// Kind returns source of events for objects of type obj in the cache, instead of the cluster wide cache
+// controllers are injected with
+func (c *Namespaced) Kind(obj runtime.Object) source.Source {
+	src := &source.Kind{Type: obj}
+	// Controller injects its cache only if none is set
+	_ = src.InjectCache(c.Cache)
+	return src
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	crcache "sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+const (
+	logNs        = "logging"
+	namespaces   = 200
+	perNamespace = 25
+	dataLen      = 4096
+)
+
+// fakeAPIServer serves lists of config maps and secrets in many namespaces, a few of which are in the logging
+// namespace, and watches which never send events
+func fakeAPIServer(b *testing.B) *httptest.Server {
+	data := strings.Repeat("x", dataLen)
+	cfgMaps := map[string]*corev1.ConfigMapList{"": {}}
+	secrets := map[string]*corev1.SecretList{"": {}}
+	for n := 0; n < namespaces; n++ {
+		ns := fmt.Sprintf("ns-%d", n)
+		if n == 0 {
+			ns = logNs
+		}
+		cfgMaps[ns] = &corev1.ConfigMapList{}
+		secrets[ns] = &corev1.SecretList{}
+		for i := 0; i < perNamespace; i++ {
+			meta := metav1.ObjectMeta{Name: fmt.Sprintf("obj-%d", i), Namespace: ns, ResourceVersion: "1"}
+			cm := corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{"data": data}}
+			s := corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{"data": []byte(data)}}
+			cfgMaps[ns].Items = append(cfgMaps[ns].Items, cm)
+			cfgMaps[""].Items = append(cfgMaps[""].Items, cm)
+			secrets[ns].Items = append(secrets[ns].Items, s)
+			secrets[""].Items = append(secrets[""].Items, s)
+		}
+	}
+
+	// Lists are encoded up front, so that they aren't counted as cached
+	lists := map[string][]byte{}
+	encode := func(path string, list apiruntime.Object, kind string) {
+		list.GetObjectKind().SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind))
+		body, err := json.Marshal(list)
+		if err != nil {
+			b.Fatal(err)
+		}
+		lists[path] = body
+	}
+	for ns := range cfgMaps {
+		prefix := "/api/v1"
+		if len(ns) > 0 {
+			prefix = fmt.Sprintf("/api/v1/namespaces/%s", ns)
+		}
+		cfgMaps[ns].ResourceVersion = "1"
+		secrets[ns].ResourceVersion = "1"
+		encode(prefix+"/configmaps", cfgMaps[ns], "ConfigMapList")
+		encode(prefix+"/secrets", secrets[ns], "SecretList")
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := lists[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+}
+
+// cachedBytes returns heap allocated by cache c once it synced config maps and secrets
+func cachedBytes(b *testing.B, c crcache.Cache) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() { _ = c.Start(stop) }()
+	// Informers wait to sync once the cache is started
+	for _, obj := range []apiruntime.Object{&corev1.ConfigMap{}, &corev1.Secret{}} {
+		if _, err := c.GetInformer(obj); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(c)
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+// BenchmarkCacheMemory compares memory of the cache fluentd and fluent-bit controllers watch, built by Get, with
+// the default cache of the manager, which the operator runs for all namespaces
+func BenchmarkCacheMemory(b *testing.B) {
+	srv := fakeAPIServer(b)
+	defer srv.Close()
+
+	cfg := &rest.Config{Host: srv.URL}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, kind := range []string{"ConfigMap", "Secret"} {
+		mapper.Add(corev1.SchemeGroupVersion.WithKind(kind), meta.RESTScopeNamespace)
+	}
+
+	for _, bc := range []struct {
+		name     string
+		newCache func() (crcache.Cache, error)
+	}{
+		{"manager", func() (crcache.Cache, error) {
+			return crcache.New(cfg, crcache.Options{Scheme: scheme.Scheme, Mapper: mapper})
+		}},
+		{"namespaced", func() (crcache.Cache, error) {
+			return newInformers(cfg, scheme.Scheme, mapper, logNs)
+		}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var total uint64
+			for i := 0; i < b.N; i++ {
+				c, err := bc.newCache()
+				if err != nil {
+					b.Fatal(err)
+				}
+				total += cachedBytes(b, c)
+			}
+			b.ReportMetric(float64(total)/float64(b.N), "cached-bytes")
+		})
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certs generates certificates and shared key securing the forward protocol between
+// fluent-bit, the event exporter and fluentd, and keeps them in secrets.
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+const (
+	keySize       = 2048
+	sharedKeySize = 32
+)
+
+// KeyPair is a PEM encoded certificate along with its private key
+type KeyPair struct {
+	Cert []byte
+	Key  []byte
+}
+
+// NewCA returns a self signed certificate authority
+func NewCA(cn string, validity time.Duration) (*KeyPair, error) {
+	tmpl, err := getTemplate(cn, validity)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return encode(tmpl, tmpl, key, key)
+}
+
+// NewServerCert returns a server certificate for hosts, which can be DNS names or IP addresses, signed by ca
+func NewServerCert(ca *KeyPair, cn string, hosts []string, validity time.Duration) (*KeyPair, error) {
+	caPair, err := tls.X509KeyPair(ca.Cert, ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := x509.ParseCertificate(caPair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := getTemplate(cn, validity)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.KeyUsage = x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return encode(tmpl, caCert, key, caPair.PrivateKey)
+}
+
+// NewSharedKey returns a random key for <security> section of fluentd
+func NewSharedKey() (string, error) {
+	b := make([]byte, sharedKeySize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NotAfter returns expiry of a PEM encoded certificate
+func NotAfter(certPEM []byte) (time.Time, error) {
+	cert, err := parse(certPEM)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+// Verify checks that PEM encoded certificate is signed by ca and valid for at least another d
+func Verify(certPEM, caPEM []byte, d time.Duration) error {
+	cert, err := parse(certPEM)
+	if err != nil {
+		return err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("No certificate found in CA")
+	}
+
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: time.Now().Add(d),
+	})
+	return err
+}
+
+func getTemplate(cn string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		// Tolerate clock skew between operator and fluent components
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(validity),
+	}, nil
+}
+
+func encode(tmpl, parent *x509.Certificate, key *rsa.PrivateKey, signer interface{}) (*KeyPair, error) {
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{
+		Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	}, nil
+}
+
+func parse(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("No certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
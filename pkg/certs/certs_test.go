@@ -0,0 +1,191 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/platform9/fluentd-operator/pkg/certs"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api_rt "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const ns = "logging"
+
+var hosts = []string{"fluentd", "fluentd.logging.svc"}
+
+func sync(t *testing.T, c client.Client, rolledOut bool) (ca, server, cl *corev1.Secret) {
+	syncers := certs.NewSecretSyncers(c, &api_rt.Scheme{}, nil, ns, hosts, rolledOut)
+	for _, s := range syncers {
+		_, err := s.Sync(context.TODO())
+		assert.Nil(t, err)
+	}
+	return syncers[0].Object().(*corev1.Secret), syncers[1].Object().(*corev1.Secret),
+		syncers[2].Object().(*corev1.Secret)
+}
+
+func TestServerCert(t *testing.T) {
+	ca, err := certs.NewCA("ca", time.Hour)
+	assert.Nil(t, err)
+
+	cert, err := certs.NewServerCert(ca, "fluentd", hosts, time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, certs.Verify(cert.Cert, ca.Cert, 0))
+	assert.NotNil(t, certs.Verify(cert.Cert, ca.Cert, 2*time.Hour))
+
+	other, err := certs.NewCA("other", time.Hour)
+	assert.Nil(t, err)
+	assert.NotNil(t, certs.Verify(cert.Cert, other.Cert, 0))
+}
+
+func TestSecrets(t *testing.T) {
+	c := fake.NewFakeClient()
+	ca, server, cl := sync(t, c, true)
+
+	assert.Nil(t, certs.Verify(server.Data[certs.CertKey], ca.Data[certs.CACertKey], certs.RotateBefore))
+	assert.Equal(t, corev1.SecretTypeTLS, server.Type)
+	assert.NotEmpty(t, ca.Data[certs.SharedKeyKey])
+	assert.Equal(t, ca.Data[certs.SharedKeyKey], server.Data[certs.SharedKeyKey])
+	assert.Equal(t, ca.Data[certs.SharedKeyKey], cl.Data[certs.SharedKeyKey])
+	assert.Equal(t, ca.Data[certs.CACertKey], cl.Data[certs.CACertKey])
+	assert.NotContains(t, cl.Data, certs.CAKeyKey)
+
+	next, err := certs.NextRotation(server)
+	assert.Nil(t, err)
+	assert.True(t, next.After(time.Now().Add(300*24*time.Hour)))
+
+	// Nothing changes while certificates are valid
+	checksum := certs.Checksum(server)
+	_, server, _ = sync(t, c, true)
+	assert.Equal(t, checksum, certs.Checksum(server))
+}
+
+func TestRotation(t *testing.T) {
+	// A CA expiring within the rotation window is replaced, along with certificates it signed
+	old, err := certs.NewCA("ca", 24*time.Hour)
+	assert.Nil(t, err)
+	c := fake.NewFakeClient(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: certs.CASecret, Namespace: ns},
+		Data: map[string][]byte{
+			certs.CACertKey:    old.Cert,
+			certs.CAKeyKey:     old.Key,
+			certs.SharedKeyKey: []byte("key"),
+		},
+	})
+
+	// Clients trust the next CA along with the current one, which still issues the server certificate
+	ca, server, cl := sync(t, c, true)
+	assert.True(t, certs.Rotating(ca))
+	assert.True(t, bytes.HasPrefix(ca.Data[certs.CACertKey], old.Cert))
+	next := ca.Data[certs.CACertKey][len(old.Cert):]
+	assert.NotEmpty(t, next)
+	assert.Equal(t, "key", string(ca.Data[certs.SharedKeyKey]))
+	assert.Nil(t, certs.Verify(server.Data[certs.CertKey], old.Cert, 0))
+	assert.Equal(t, ca.Data[certs.CACertKey], server.Data[certs.CACertKey])
+	assert.Equal(t, ca.Data[certs.CACertKey], cl.Data[certs.CACertKey])
+
+	// Nothing changes until pods mount the secrets
+	checksum := certs.Checksum(server)
+	_, server, _ = sync(t, c, false)
+	assert.Equal(t, checksum, certs.Checksum(server))
+
+	// Server certificate is then issued by the next CA, which clients already trust
+	ca, server, cl = sync(t, c, true)
+	assert.True(t, certs.Rotating(ca))
+	assert.Nil(t, certs.Verify(server.Data[certs.CertKey], next, certs.RotateBefore))
+	assert.Nil(t, certs.Verify(server.Data[certs.CertKey], cl.Data[certs.CACertKey], certs.RotateBefore))
+
+	checksum = certs.Checksum(server)
+	_, server, _ = sync(t, c, false)
+	assert.Equal(t, checksum, certs.Checksum(server))
+
+	// Previous CA is dropped last
+	ca, server, cl = sync(t, c, true)
+	assert.False(t, certs.Rotating(ca))
+	assert.Equal(t, next, ca.Data[certs.CACertKey])
+	assert.Equal(t, next, server.Data[certs.CACertKey])
+	assert.Equal(t, next, cl.Data[certs.CACertKey])
+}
+
+func TestRolledOut(t *testing.T) {
+	c := fake.NewFakeClient()
+	ok, err := certs.RolledOut(c, ns)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	_, server, cl := sync(t, c, true)
+	replicas := int32(2)
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "fluentd", Namespace: ns, Generation: 2},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{certs.ChecksumAnnotation: certs.Checksum(server)},
+				},
+			},
+		},
+		Status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2},
+	}
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "fluent-bit", Namespace: ns, Generation: 1},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{certs.ChecksumAnnotation: certs.Checksum(cl)},
+				},
+			},
+		},
+		Status: appsv1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3,
+			NumberAvailable: 3},
+	}
+	assert.Nil(t, c.Create(context.TODO(), deploy))
+	assert.Nil(t, c.Create(context.TODO(), ds))
+
+	// A pod of the previous version is still running
+	ok, err = certs.RolledOut(c, ns)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	deploy.Status.Replicas = 2
+	assert.Nil(t, c.Update(context.TODO(), deploy))
+	ok, err = certs.RolledOut(c, ns)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// Pods mount secrets of another version
+	ds.Spec.Template.Annotations[certs.ChecksumAnnotation] = "other"
+	assert.Nil(t, c.Update(context.TODO(), ds))
+	ok, err = certs.RolledOut(c, ns)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	_, _, cl := sync(t, fake.NewFakeClient(), true)
+	cfg, err := certs.ClientTLSConfig(cl, "fluentd")
+	assert.Nil(t, err)
+	assert.Equal(t, "fluentd", cfg.ServerName)
+
+	_, err = certs.ClientTLSConfig(&corev1.Secret{}, "fluentd")
+	assert.NotNil(t, err)
+}
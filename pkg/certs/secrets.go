@@ -0,0 +1,354 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/presslabs/controller-util/syncer"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CASecret holds the certificate authority, it is only read by the operator
+	CASecret = "fluent-forward-ca"
+	// ServerSecret holds certificate of fluentd forward input
+	ServerSecret = "fluentd-forward-tls"
+	// ClientSecret holds what forwarding clients need to verify and authenticate to fluentd
+	ClientSecret = "fluent-forward-client"
+
+	// CACertKey is the secret key of trusted CA certificates
+	CACertKey = "ca.crt"
+	// CAKeyKey is the secret key of private key of the CA issuing certificates
+	CAKeyKey = "ca.key"
+	// CertKey is the secret key of server certificate
+	CertKey = corev1.TLSCertKey
+	// KeyKey is the secret key of server private key
+	KeyKey = corev1.TLSPrivateKeyKey
+	// SharedKeyKey is the secret key of the forward shared key
+	SharedKeyKey = "shared_key"
+
+	// SharedKeyEnv is the environment variable exposing shared key to fluentd and fluent-bit
+	SharedKeyEnv = "FLUENT_FORWARD_SHARED_KEY"
+	// FluentdMountPath is where fluentd mounts ServerSecret
+	FluentdMountPath = "/fluentd/tls"
+	// FluentbitMountPath is where fluent-bit mounts ClientSecret
+	FluentbitMountPath = "/fluent-bit/tls"
+
+	// ChecksumAnnotation on pod templates rolls pods mounting a secret when it changes
+	ChecksumAnnotation = "fluentd-operator/forward-tls-checksum"
+
+	// RotateBefore is how long before expiry certificates are replaced
+	RotateBefore = 30 * 24 * time.Hour
+
+	// signingCertKey is the secret key of certificate of the CA issuing certificates, CACertKey holds it along
+	// with CAs trusted while they are rotated. CA secrets lacking it issue with CACertKey.
+	signingCertKey = "signing.crt"
+	// nextCertKey and nextKeyKey hold the CA replacing the signing CA, once clients trust it
+	nextCertKey = "next.crt"
+	nextKeyKey  = "next.key"
+
+	caValidity     = 5 * 365 * 24 * time.Hour
+	serverValidity = 365 * 24 * time.Hour
+	caName         = "fluentd-operator-ca"
+)
+
+// Labels defines operator enforced labels for secrets
+var Labels = map[string]string{
+	"created_by": "fluentd-operator",
+}
+
+type caSyncer struct {
+	input     *corev1.Secret
+	rolledOut bool
+}
+
+type serverSyncer struct {
+	input *corev1.Secret
+	ca    *corev1.Secret
+	hosts []string
+}
+
+type clientSyncer struct {
+	input *corev1.Secret
+	ca    *corev1.Secret
+}
+
+// NewSecretSyncers returns syncers for CA, server and client secrets in namespace ns, in the order they must
+// be synced. Server certificate is issued for hosts. Secrets are owned by owner, unless it is nil. rolledOut
+// tells whether pods mount the current secrets, see RolledOut; rotation of the CA waits for it between steps.
+func NewSecretSyncers(c client.Client, scheme *runtime.Scheme, owner runtime.Object, ns string,
+	hosts []string, rolledOut bool) []syncer.Interface {
+	ca := newSecret(CASecret, ns)
+	server := newSecret(ServerSecret, ns)
+	cl := newSecret(ClientSecret, ns)
+
+	return []syncer.Interface{
+		syncer.NewObjectSyncer("Secret", owner, ca, c, scheme, (&caSyncer{ca, rolledOut}).SyncFn),
+		syncer.NewObjectSyncer("Secret", owner, server, c, scheme, (&serverSyncer{server, ca, hosts}).SyncFn),
+		syncer.NewObjectSyncer("Secret", owner, cl, c, scheme, (&clientSyncer{cl, ca}).SyncFn),
+	}
+}
+
+func newSecret(name, ns string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+	}
+}
+
+func setLabels(s *corev1.Secret) {
+	if len(s.ObjectMeta.Labels) == 0 {
+		s.ObjectMeta.Labels = map[string]string{}
+	}
+	for k, v := range Labels {
+		s.ObjectMeta.Labels[k] = v
+	}
+	if s.Data == nil {
+		s.Data = map[string][]byte{}
+	}
+}
+
+// signingCert returns certificate of the CA issuing certificates
+func signingCert(data map[string][]byte) []byte {
+	if len(data[signingCertKey]) > 0 {
+		return data[signingCertKey]
+	}
+	return data[CACertKey]
+}
+
+// SyncFn creates the CA and shared key, and replaces the CA before it expires. fluentd and fluent-bit roll
+// independently, so the CA is replaced in steps, each waiting for pods to mount secrets of the previous one:
+// clients trust the next CA along with the current one, then the server certificate is issued by the next CA,
+// then the previous CA is dropped.
+func (s *caSyncer) SyncFn() error {
+	out := s.input
+	setLabels(out)
+
+	signing := signingCert(out.Data)
+	out.Data[signingCertKey] = signing
+	next := out.Data[nextCertKey]
+	switch {
+	case len(next) > 0 && (s.rolledOut || Verify(signing, signing, 0) != nil):
+		out.Data[signingCertKey], out.Data[CAKeyKey] = next, out.Data[nextKeyKey]
+		delete(out.Data, nextCertKey)
+		delete(out.Data, nextKeyKey)
+	case len(next) > 0:
+		// Waiting for clients to trust the next CA
+	case Verify(signing, signing, 0) != nil:
+		ca, err := NewCA(caName, caValidity)
+		if err != nil {
+			return err
+		}
+		out.Data[CACertKey], out.Data[signingCertKey], out.Data[CAKeyKey] = ca.Cert, ca.Cert, ca.Key
+	case Verify(signing, signing, RotateBefore) != nil:
+		ca, err := NewCA(caName, caValidity)
+		if err != nil {
+			return err
+		}
+		out.Data[CACertKey] = append(append([]byte{}, signing...), ca.Cert...)
+		out.Data[nextCertKey], out.Data[nextKeyKey] = ca.Cert, ca.Key
+	case !bytes.Equal(out.Data[CACertKey], signing) && s.rolledOut:
+		// No pod mounts a certificate issued by the previous CA
+		out.Data[CACertKey] = signing
+	}
+
+	if len(out.Data[SharedKeyKey]) == 0 {
+		key, err := NewSharedKey()
+		if err != nil {
+			return err
+		}
+		out.Data[SharedKeyKey] = []byte(key)
+	}
+
+	return nil
+}
+
+// SyncFn issues the server certificate when it is missing, about to expire or signed by another CA
+func (s *serverSyncer) SyncFn() error {
+	out := s.input
+	setLabels(out)
+	out.Type = corev1.SecretTypeTLS
+
+	// Certificates don't outlive the CA, which issues them until clients trust the next one
+	signing := signingCert(s.ca.Data)
+	d := RotateBefore
+	if Verify(signing, signing, RotateBefore) != nil {
+		d = 0
+	}
+	if Verify(out.Data[CertKey], signing, d) != nil {
+		ca := &KeyPair{Cert: signing, Key: s.ca.Data[CAKeyKey]}
+		cert, err := NewServerCert(ca, s.hosts[0], s.hosts, serverValidity)
+		if err != nil {
+			return err
+		}
+		out.Data[CertKey], out.Data[KeyKey] = cert.Cert, cert.Key
+	}
+
+	out.Data[CACertKey] = s.ca.Data[CACertKey]
+	out.Data[SharedKeyKey] = s.ca.Data[SharedKeyKey]
+	return nil
+}
+
+// SyncFn copies CA certificate and shared key for clients
+func (s *clientSyncer) SyncFn() error {
+	out := s.input
+	setLabels(out)
+
+	out.Data[CACertKey] = s.ca.Data[CACertKey]
+	out.Data[SharedKeyKey] = s.ca.Data[SharedKeyKey]
+	return nil
+}
+
+// Rotating returns whether CA secret s is replacing its CA, which is then synced again once pods mount the
+// current secrets
+func Rotating(s *corev1.Secret) bool {
+	return len(s.Data[nextCertKey]) > 0 || !bytes.Equal(s.Data[CACertKey], signingCert(s.Data))
+}
+
+// RolledOut returns whether pods of deployments and daemonsets in namespace ns mounting server or client
+// secret run with their current data, as told by ChecksumAnnotation of pod templates. Secrets not yet synced
+// with the CA secret aren't rolled out either.
+func RolledOut(c client.Reader, ns string) (bool, error) {
+	get := func(name string) (*corev1.Secret, error) {
+		s := &corev1.Secret{}
+		err := c.Get(context.TODO(), types.NamespacedName{Namespace: ns, Name: name}, s)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return s, err
+	}
+
+	ca, err := get(CASecret)
+	if ca == nil {
+		return err == nil, err
+	}
+
+	checksums := map[string]bool{}
+	for _, name := range []string{ServerSecret, ClientSecret} {
+		s, err := get(name)
+		if err != nil {
+			return false, err
+		}
+		if s == nil {
+			continue
+		}
+		if !bytes.Equal(s.Data[CACertKey], ca.Data[CACertKey]) {
+			return false, nil
+		}
+		if name == ServerSecret && Verify(s.Data[CertKey], signingCert(ca.Data), 0) != nil {
+			return false, nil
+		}
+		checksums[Checksum(s)] = true
+	}
+
+	deploys := &appsv1.DeploymentList{}
+	if err := c.List(context.TODO(), deploys, client.InNamespace(ns)); err != nil {
+		return false, err
+	}
+	for _, d := range deploys.Items {
+		checksum, ok := d.Spec.Template.Annotations[ChecksumAnnotation]
+		if !ok {
+			continue
+		}
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		st := d.Status
+		if !checksums[checksum] || st.ObservedGeneration < d.Generation || st.UpdatedReplicas != replicas ||
+			st.Replicas != replicas || st.AvailableReplicas != replicas {
+			return false, nil
+		}
+	}
+
+	daemonsets := &appsv1.DaemonSetList{}
+	if err := c.List(context.TODO(), daemonsets, client.InNamespace(ns)); err != nil {
+		return false, err
+	}
+	for _, d := range daemonsets.Items {
+		checksum, ok := d.Spec.Template.Annotations[ChecksumAnnotation]
+		if !ok {
+			continue
+		}
+		st := d.Status
+		if !checksums[checksum] || st.ObservedGeneration < d.Generation ||
+			st.UpdatedNumberScheduled != st.DesiredNumberScheduled || st.NumberAvailable != st.DesiredNumberScheduled {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// NextRotation returns when the earliest certificate of a secret has to be replaced
+func NextRotation(s *corev1.Secret) (time.Time, error) {
+	ret := time.Time{}
+	for _, k := range []string{CACertKey, CertKey} {
+		if len(s.Data[k]) == 0 {
+			continue
+		}
+		t, err := NotAfter(s.Data[k])
+		if err != nil {
+			return ret, err
+		}
+		if t = t.Add(-RotateBefore); ret.IsZero() || t.Before(ret) {
+			ret = t
+		}
+	}
+	return ret, nil
+}
+
+// Checksum returns a digest of secret data, it changes whenever pods mounting the secret need a restart
+func Checksum(s *corev1.Secret) string {
+	keys := []string{}
+	for k := range s.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%x\n", k, s.Data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ClientTLSConfig returns TLS configuration verifying fluentd against CA in client secret s
+func ClientTLSConfig(s *corev1.Secret, serverName string) (*tls.Config, error) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(s.Data[CACertKey]) {
+		return nil, fmt.Errorf("No CA certificate in secret %s", s.Name)
+	}
+
+	return &tls.Config{
+		RootCAs:    roots,
+		ServerName: serverName,
+	}, nil
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package event
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/platform9/fluentd-operator/pkg/cache"
+	"github.com/platform9/fluentd-operator/pkg/certs"
+	"github.com/platform9/fluentd-operator/pkg/forward"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_event")
+
+const (
+	// TagPrefix is prepended to namespace of an event to build its fluentd tag
+	TagPrefix = "k8s.events"
+	// Number of events remembered to avoid sending the same version twice
+	sentCacheSize = 4096
+)
+
+// Add creates a new Event Controller and adds it to the Manager, if event export is enabled.
+func Add(mgr manager.Manager, opts *options.Config) error {
+	if !opts.ExportEvents {
+		return nil
+	}
+
+	cfg := forward.Config{
+		Address:    fmt.Sprintf("%s:%d", opts.ForwardHost, opts.ForwardPort),
+		RequireAck: true,
+	}
+
+	// Events are watched cluster wide, while forward client secret is read from cache of logging namespace
+	secrets, err := cache.Get(mgr, opts.LogNs)
+	if err != nil {
+		return err
+	}
+
+	r, err := newReconciler(mgr.GetClient(), secrets.Client, opts, cfg)
+	if err != nil {
+		return err
+	}
+
+	return add(mgr, r)
+}
+
+// newReconciler returns a new reconciler forwarding events to fluentd described by cfg
+func newReconciler(c client.Client, secrets client.Reader, opts *options.Config, cfg forward.Config) (*ReconcileEvent, error) {
+	sent, err := lru.New(sentCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReconcileEvent{
+		client:    c,
+		secrets:   secrets,
+		cfg:       cfg,
+		secure:    opts.SecureForward,
+		ns:        opts.LogNs,
+		sent:      sent,
+		startTime: time.Now(),
+	}, nil
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	c, err := controller.New("event-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Events are immutable records, their deletion is housekeeping and not worth forwarding
+	pred := predicate.Funcs{
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+	}
+
+	err = c.Watch(&source.Kind{Type: &corev1.Event{}}, &handler.EnqueueRequestForObject{}, pred)
+	if err != nil {
+		log.Error(err, "Error adding watch")
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileEvent implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileEvent{}
+
+// ReconcileEvent forwards kubernetes events to fluentd
+type ReconcileEvent struct {
+	client    client.Client
+	secrets   client.Reader
+	cfg       forward.Config
+	forwarder *forward.Client
+	// secure makes the forwarder use TLS and shared key from the forward client secret
+	secure bool
+	// ns is the namespace of the forward client secret
+	ns string
+	// secretVersion is resource version of the client secret forwarder was built with
+	secretVersion string
+	// sent maps UID of forwarded events to their resource version
+	sent *lru.Cache
+	// Events last seen before startTime were forwarded by a previous run, or missed for good
+	startTime time.Time
+}
+
+// Reconcile forwards the event once for every change in it, such as count of occurrences
+func (r *ReconcileEvent) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	instance := &corev1.Event{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	ts := getTimestamp(instance)
+	if ts.Before(r.startTime) {
+		return reconcile.Result{}, nil
+	}
+
+	if rv, ok := r.sent.Get(instance.UID); ok && rv == instance.ResourceVersion {
+		return reconcile.Result{}, nil
+	}
+
+	fwd, err := r.getForwarder()
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	tag := fmt.Sprintf("%s.%s", TagPrefix, instance.Namespace)
+	if err := fwd.Post(tag, ts, getRecord(instance)); err != nil {
+		log.Error(err, "Failed to forward event", "Request.Namespace", request.Namespace, "Request.Name", request.Name)
+		return reconcile.Result{}, err
+	}
+
+	r.sent.Add(instance.UID, instance.ResourceVersion)
+	return reconcile.Result{}, nil
+}
+
+// getForwarder returns forward client, rebuilding it when forward client secret changes
+func (r *ReconcileEvent) getForwarder() (*forward.Client, error) {
+	if !r.secure {
+		if r.forwarder == nil {
+			r.forwarder = forward.NewClient(r.cfg)
+		}
+		return r.forwarder, nil
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: r.ns, Name: certs.ClientSecret}
+	if err := r.secrets.Get(context.TODO(), key, secret); err != nil {
+		return nil, err
+	}
+
+	if r.forwarder != nil && secret.ResourceVersion == r.secretVersion {
+		return r.forwarder, nil
+	}
+
+	host, _, err := net.SplitHostPort(r.cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := r.cfg
+	cfg.SharedKey = string(secret.Data[certs.SharedKeyKey])
+	if cfg.TLSConfig, err = certs.ClientTLSConfig(secret, host); err != nil {
+		return nil, err
+	}
+
+	if r.forwarder != nil {
+		r.forwarder.Close()
+	}
+	r.forwarder = forward.NewClient(cfg)
+	r.secretVersion = secret.ResourceVersion
+	return r.forwarder, nil
+}
+
+// getTimestamp returns time of the latest occurrence of an event
+func getTimestamp(e *corev1.Event) time.Time {
+	switch {
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.Time
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+func getRecord(e *corev1.Event) map[string]interface{} {
+	count := e.Count
+	if e.Series != nil {
+		count = e.Series.Count
+	}
+
+	return map[string]interface{}{
+		"type":      e.Type,
+		"reason":    e.Reason,
+		"message":   e.Message,
+		"count":     int64(count),
+		"namespace": e.Namespace,
+		"name":      e.Name,
+		"involved_object": map[string]interface{}{
+			"kind":      e.InvolvedObject.Kind,
+			"namespace": e.InvolvedObject.Namespace,
+			"name":      e.InvolvedObject.Name,
+			"uid":       string(e.InvolvedObject.UID),
+			"field":     e.InvolvedObject.FieldPath,
+		},
+		"source": map[string]interface{}{
+			"component": e.Source.Component,
+			"host":      e.Source.Host,
+		},
+		"first_timestamp": e.FirstTimestamp.UTC().Format(time.RFC3339),
+		"last_timestamp":  getTimestamp(e).UTC().Format(time.RFC3339),
+	}
+}
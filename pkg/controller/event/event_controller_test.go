@@ -0,0 +1,143 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package event
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/platform9/fluentd-operator/pkg/certs"
+	"github.com/platform9/fluentd-operator/pkg/forward"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api_rt "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func getEvent(name string, last time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			UID:             types.UID(name),
+			ResourceVersion: "1",
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: "default",
+			Name:      "web-0",
+		},
+		Reason:        "BackOff",
+		Message:       "Back-off restarting failed container",
+		Type:          corev1.EventTypeWarning,
+		Count:         3,
+		LastTimestamp: metav1.NewTime(last),
+	}
+}
+
+func getRequest(name string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}
+}
+
+func TestForwardEvent(t *testing.T) {
+	s, err := forward.NewTestServer(forward.ServerConfig{})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	now := time.Now()
+	c := fake.NewFakeClient(getEvent("recent", now.Add(time.Minute)), getEvent("old", now.Add(-time.Hour)))
+	r, err := newReconciler(c, c, options.New(), forward.Config{Address: s.Addr(), RequireAck: true})
+	assert.Nil(t, err)
+
+	for _, name := range []string{"old", "recent", "recent", "missing"} {
+		_, err = r.Reconcile(getRequest(name))
+		assert.Nil(t, err)
+	}
+
+	select {
+	case rec := <-s.Records():
+		assert.Equal(t, "k8s.events.default", rec.Tag)
+		assert.Equal(t, forward.MessageMode, rec.Mode)
+		assert.Equal(t, "BackOff", rec.Record["reason"])
+		assert.Equal(t, int64(3), rec.Record["count"])
+		assert.Equal(t, "web-0", rec.Record["involved_object"].(map[string]interface{})["name"])
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "event was not forwarded")
+	}
+
+	// Older and already forwarded events are skipped
+	select {
+	case rec := <-s.Records():
+		assert.Fail(t, "unexpected record", "%v", rec)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestForwardError(t *testing.T) {
+	s, err := forward.NewTestServer(forward.ServerConfig{})
+	assert.Nil(t, err)
+	addr := s.Addr()
+	s.Close()
+
+	c := fake.NewFakeClient(getEvent("recent", time.Now().Add(time.Minute)))
+	r, err := newReconciler(c, c, options.New(), forward.Config{Address: addr, Timeout: time.Second})
+	assert.Nil(t, err)
+
+	_, err = r.Reconcile(getRequest("recent"))
+	assert.NotNil(t, err)
+}
+
+func TestForwardSecure(t *testing.T) {
+	opts := options.New()
+	opts.SecureForward = true
+
+	c := fake.NewFakeClient(getEvent("recent", time.Now().Add(time.Minute)))
+	syncers := certs.NewSecretSyncers(c, &api_rt.Scheme{}, nil, opts.LogNs, []string{"127.0.0.1"}, true)
+	for _, sync := range syncers {
+		_, err := sync.Sync(context.TODO())
+		assert.Nil(t, err)
+	}
+
+	server := syncers[1].Object().(*corev1.Secret)
+	pair, err := tls.X509KeyPair(server.Data[certs.CertKey], server.Data[certs.KeyKey])
+	assert.Nil(t, err)
+
+	s, err := forward.NewTestServer(forward.ServerConfig{
+		SharedKey: string(server.Data[certs.SharedKeyKey]),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{pair}},
+	})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	r, err := newReconciler(c, c, opts, forward.Config{Address: s.Addr(), RequireAck: true})
+	assert.Nil(t, err)
+
+	_, err = r.Reconcile(getRequest("recent"))
+	assert.Nil(t, err)
+
+	select {
+	case rec := <-s.Records():
+		assert.Equal(t, "BackOff", rec.Record["reason"])
+	case err := <-s.Errors():
+		assert.Fail(t, "server error", err.Error())
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "event was not forwarded")
+	}
+}
@@ -14,32 +14,44 @@ limitations under the License.
 package fluentbit
 
 import (
+	loggingv1alpha1 "github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/cache"
 	"github.com/platform9/fluentd-operator/pkg/fluentbit"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/pkg/utils"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
-	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var log = logf.Log.WithName("controller_fluentbit")
 
 // Add creates a new Fluentbit Controller and adds it to the Manager.
-func Add(mgr manager.Manager) error {
-	rc := newReconciler(mgr)
-	return add(mgr, rc)
+func Add(mgr manager.Manager, cfg *options.Config) error {
+	rc, err := newReconciler(mgr, cfg)
+	if err != nil {
+		return err
+	}
+
+	c, err := cache.Get(mgr, cfg.LogNs)
+	if err != nil {
+		return err
+	}
+	return add(mgr, c, rc)
 }
 
 // newReconciler returns a new fluentbit.Reconciler
-func newReconciler(mgr manager.Manager) *fluentbit.Reconciler {
-	return fluentbit.New(mgr)
+func newReconciler(mgr manager.Manager, cfg *options.Config) (*fluentbit.Reconciler, error) {
+	return fluentbit.New(mgr, cfg)
 }
 
-// add adds a new Controller to mgr
-func add(mgr manager.Manager, r *fluentbit.Reconciler) error {
+// add adds a new Controller to mgr, watching objects in cache of logging namespace
+func add(mgr manager.Manager, informers *cache.Namespaced, r *fluentbit.Reconciler) error {
 	// Create a new controller
 	c, err := controller.New("fluentbit-controller", mgr, controller.Options{
 		Reconciler: r,
@@ -49,12 +61,23 @@ func add(mgr manager.Manager, r *fluentbit.Reconciler) error {
 		return err
 	}
 
-	if err := c.Watch(&source.Kind{Type: &appsv1.DaemonSet{}}, &handler.EnqueueRequestForObject{}); err != nil {
-		log.Error(err, "Error adding watch")
-		return err
+	// Every object operator creates for fluent-bit is owned by LoggingConfig, whose request syncs them all
+	toOwner := &handler.EnqueueRequestForOwner{
+		OwnerType:    &loggingv1alpha1.LoggingConfig{},
+		IsController: true,
+	}
+
+	// Secrets hold forward certificates mounted into pods
+	for _, obj := range []runtime.Object{&appsv1.DaemonSet{}, &corev1.ConfigMap{}, &corev1.Secret{}} {
+		err := c.Watch(informers.Kind(obj), toOwner, utils.CreatedByOperator())
+		if err != nil {
+			log.Error(err, "Error adding watch")
+			return err
+		}
 	}
 
-	return c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestForObject{})
+	// LoggingConfig customizes fluent-bit pods
+	return c.Watch(informers.Kind(&loggingv1alpha1.LoggingConfig{}), &handler.EnqueueRequestForObject{})
 }
 
 var _ reconcile.Reconciler = &fluentbit.Reconciler{}
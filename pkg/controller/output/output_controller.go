@@ -14,18 +14,21 @@ limitations under the License.
 package output
 
 import (
-	"bytes"
 	"context"
-	"fmt"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/platform9/fluentd-operator/pkg/fluentd"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/pkg/resources"
+	"github.com/platform9/fluentd-operator/pkg/search"
 
 	loggingv1alpha1 "github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
-	"github.com/platform9/fluentd-operator/pkg/resources"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -35,19 +38,42 @@ import (
 
 var log = logf.Log.WithName("controller_output")
 
+// Finalizer keeps a deleted Output until fluentd configuration without it is applied
+const Finalizer = "logging.pf9.io/output"
+
+// loadRetry is how long to wait for fluentd to load configuration, until kubelet updates the config map it mounts
+const loadRetry = 10 * time.Second
+
+// refresher applies configuration of all Outputs to fluentd
+type refresher interface {
+	Refresh() error
+	Flush() error
+}
+
 // Add creates a new Output Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
-func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+func Add(mgr manager.Manager, cfg *options.Config) error {
+	r, err := newReconciler(mgr, cfg)
+	if err != nil {
+		return err
+	}
+	return add(mgr, r)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager, cfg *options.Config) (reconcile.Reconciler, error) {
+	fd, err := fluentd.New(mgr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ReconcileOutput{
 		client:  mgr.GetClient(),
+		reader:  mgr.GetAPIReader(),
 		scheme:  mgr.GetScheme(),
-		fluentd: fluentd.New(mgr),
-	}
+		cfg:     cfg,
+		fluentd: fd,
+	}, nil
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -75,9 +101,12 @@ var _ reconcile.Reconciler = &ReconcileOutput{}
 type ReconcileOutput struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client  client.Client
+	client client.Client
+	// Secrets referenced by outputs are read from apiserver, like fluentd configuration is rendered
+	reader  client.Reader
 	scheme  *runtime.Scheme
-	fluentd *fluentd.Reconciler
+	cfg     *options.Config
+	fluentd refresher
 }
 
 // Reconcile reads that state of the cluster for a Output object and makes changes based on the state read
@@ -99,49 +128,96 @@ func (r *ReconcileOutput) Reconcile(request reconcile.Request) (reconcile.Result
 		}
 	}
 
-	buff, err := getFluentdConfig(r.client)
-	if err != nil {
-		return reconcile.Result{}, err
+	found := err == nil
+	deleting := found && !instance.DeletionTimestamp.IsZero()
+	if found && !deleting && !hasFinalizer(instance) {
+		controllerutil.AddFinalizer(instance, Finalizer)
+		if err := r.client.Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// Expired outputs are deleted, which requeues them
+	var result reconcile.Result
+	if found && !deleting && instance.Spec.TTL != nil {
+		left := time.Until(instance.CreationTimestamp.Add(instance.Spec.TTL.Duration))
+		if left <= 0 {
+			reqLogger.Info("Deleting expired Output")
+			return reconcile.Result{}, client.IgnoreNotFound(r.client.Delete(context.TODO(), instance))
+		}
+		result.RequeueAfter = left
 	}
 
 	// Update configmap for fluentd
 	log.Info("Refreshing fluentd...")
-	return reconcile.Result{}, r.fluentd.Refresh(buff)
-}
+	err = r.fluentd.Refresh()
+	if err == fluentd.ErrNotLoaded {
+		reqLogger.Info("Waiting for fluentd to load configuration")
+		return reconcile.Result{RequeueAfter: loadRetry}, nil
+	}
+	if _, partial := err.(*fluentd.RenderError); err != nil && !partial {
+		return reconcile.Result{}, err
+	}
+
+	if found && !deleting {
+		if err := r.install(reqLogger, instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
 
-func getFluentdConfig(cl client.Client) ([]byte, error) {
-	// Simple algorithm to render all outputs once one changes. This lets us keep thing simple and write entire config
-	// as one.
-	instances := &loggingv1alpha1.OutputList{}
-	lo := client.ListOptions{}
+	// Other outputs failing to render don't hold back deletion, they are retried along with this request
+	if deleting && hasFinalizer(instance) {
+		if err := r.finalize(reqLogger, instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	return result, err
+}
 
-	err := cl.List(context.TODO(), instances, &lo)
+// finalize releases a deleted Output once fluentd loaded configuration without it. fluentd no longer routes
+// logs to it after refresh, what it buffered is flushed before it is removed from configuration, if enabled.
+func (r *ReconcileOutput) finalize(reqLogger logr.Logger, instance *loggingv1alpha1.Output) error {
+	if r.cfg.FlushOnDelete && instance.Annotations[fluentd.DrainedAnnotation] != "true" {
+		reqLogger.Info("Flushing buffers of deleted Output")
+		if err := r.fluentd.Flush(); err != nil {
+			return err
+		}
 
-	if err != nil {
-		return []byte{}, err
+		// Update requeues the output, which is then left out of configuration
+		if instance.Annotations == nil {
+			instance.Annotations = map[string]string{}
+		}
+		instance.Annotations[fluentd.DrainedAnnotation] = "true"
+		return r.client.Update(context.TODO(), instance)
 	}
 
-	// Source rendering is not configurable yet.
-	renderers := []resources.Resource{
-		resources.NewSystem(),
-		resources.NewSource(),
+	reqLogger.Info("Releasing deleted Output")
+	controllerutil.RemoveFinalizer(instance, Finalizer)
+	return r.client.Update(context.TODO(), instance)
+}
+
+// install sets up the datastore of an Output, if the operator manages it, e.g. index templates of elasticsearch
+func (r *ReconcileOutput) install(reqLogger logr.Logger, instance *loggingv1alpha1.Output) error {
+	o := resources.NewOutput(r.reader, instance)
+	if _, err := o.Render(); err != nil {
+		// Render errors are reported by refresh
+		return nil
 	}
 
-	for i := range instances.Items {
-		renderers = append(renderers, resources.NewOutput(cl, &instances.Items[i]))
+	setup := o.SearchSetup()
+	if setup == nil {
+		return nil
 	}
 
-	var buff []byte
-	var newline bytes.Buffer
-	fmt.Fprintf(&newline, "\n\n")
-	for _, r := range renderers {
-		out, err := r.Render()
-		if err != nil {
-			return []byte{}, err
+	reqLogger.Info("Installing index template and policy", "URL", setup.URL)
+	return search.Install(setup)
+}
+
+func hasFinalizer(o *loggingv1alpha1.Output) bool {
+	for _, f := range o.GetFinalizers() {
+		if f == Finalizer {
+			return true
 		}
-		buff = append(buff, out...)
-		buff = append(buff, newline.Bytes()...)
 	}
-
-	return buff, nil
+	return false
 }
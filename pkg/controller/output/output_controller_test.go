@@ -15,95 +15,103 @@ package output
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"testing"
 
+	"github.com/platform9/fluentd-operator/pkg/apis"
 	loggingv1alpha1 "github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/fluentd"
+	"github.com/platform9/fluentd-operator/pkg/options"
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-type TestClient struct {
-	sw client.StatusWriter
+// fakeFluentd records calls of the output controller, failing them with refreshErr and flushErr
+type fakeFluentd struct {
+	refreshes  int
+	flushes    int
+	refreshErr error
+	flushErr   error
 }
 
-type TestStatusWriter struct {
+func (f *fakeFluentd) Refresh() error {
+	f.refreshes++
+	return f.refreshErr
 }
 
-func NewTestClient() client.Client {
-	return &TestClient{}
+func (f *fakeFluentd) Flush() error {
+	f.flushes++
+	return f.flushErr
 }
 
-func (t *TestClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
-	return nil
+func TestMain(t *testing.T) {
+	// Fake client serves Outputs
+	assert.Nil(t, apis.AddToScheme(scheme.Scheme))
 }
 
-func (t *TestClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
-	return nil
-}
-
-func (t *TestClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
-	return nil
-}
-
-func (t *TestClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
-	return nil
-}
-
-func (t *TestClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
-	return nil
-}
-
-func (t *TestClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
-	return nil
-}
-
-func (t *TestClient) List(ctx context.Context, list runtime.Object, opt ...client.ListOption) error {
-	decoder := scheme.Codecs.UniversalDecoder()
-	var objs runtime.Object
-	objs = &loggingv1alpha1.OutputList{
-		Items: []loggingv1alpha1.Output{
-			loggingv1alpha1.Output{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "ES-Output",
-				},
-				Spec: loggingv1alpha1.OutputSpec{
-					Type: "elasticsearch",
-					Params: []loggingv1alpha1.Param{
-						loggingv1alpha1.Param{
-							Name:  "user",
-							Value: "fake-user",
-						},
-						loggingv1alpha1.Param{
-							Name:  "password",
-							Value: "fake-password",
-						},
-					},
-				},
-			},
+func TestFinalize(t *testing.T) {
+	now := metav1.Now()
+	obj := &loggingv1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "fake",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{Finalizer},
 		},
+		Spec: loggingv1alpha1.OutputSpec{Type: "stdout"},
 	}
-
-	j, err := json.Marshal(objs)
-	if err != nil {
-		return err
+	c := fake.NewFakeClient(obj)
+	f := &fakeFluentd{}
+	r := &ReconcileOutput{client: c, reader: c, scheme: scheme.Scheme, cfg: options.New(), fluentd: f}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "fake"}}
+
+	get := func() *loggingv1alpha1.Output {
+		out := &loggingv1alpha1.Output{}
+		assert.Nil(t, c.Get(context.TODO(), req.NamespacedName, out))
+		return out
 	}
 
-	decoder.Decode(j, nil, list)
-	return nil
-}
+	// Buffers aren't flushed until fluentd stops routing logs to the output
+	f.refreshErr = fmt.Errorf("calling fluentd config.reload: 500 Internal Server Error")
+	_, err := r.Reconcile(req)
+	assert.NotNil(t, err)
 
-func (t *TestClient) Status() client.StatusWriter {
-	return t.sw
-}
+	f.refreshErr = fluentd.ErrNotLoaded
+	result, err := r.Reconcile(req)
+	assert.Nil(t, err)
+	assert.Equal(t, loadRetry, result.RequeueAfter)
+	assert.Equal(t, 0, f.flushes)
+
+	f.refreshErr = nil
+	f.flushErr = fmt.Errorf("connection refused")
+	_, err = r.Reconcile(req)
+	assert.NotNil(t, err)
+	assert.NotContains(t, get().Annotations, fluentd.DrainedAnnotation)
+
+	// Flushed output is marked drained and kept until fluentd loads configuration without it
+	f.flushErr = nil
+	_, err = r.Reconcile(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, f.flushes)
+	assert.Equal(t, "true", get().Annotations[fluentd.DrainedAnnotation])
+	assert.Contains(t, get().Finalizers, Finalizer)
+
+	f.refreshErr = fmt.Errorf("calling fluentd config.reload: 500 Internal Server Error")
+	_, err = r.Reconcile(req)
+	assert.NotNil(t, err)
+	assert.Contains(t, get().Finalizers, Finalizer)
+
+	f.refreshErr = fluentd.ErrNotLoaded
+	_, err = r.Reconcile(req)
+	assert.Nil(t, err)
+	assert.Contains(t, get().Finalizers, Finalizer)
 
-func TestFluentdConfig(t *testing.T) {
-	cl := NewTestClient()
-	buf, err := getFluentdConfig(cl)
-	t.Log(err)
+	f.refreshErr = nil
+	_, err = r.Reconcile(req)
 	assert.Nil(t, err)
-	assert.NotEmpty(t, buf)
+	assert.Equal(t, 2, f.flushes)
+	assert.Empty(t, get().Finalizers)
 }
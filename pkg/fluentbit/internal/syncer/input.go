@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/platform9/fluentd-operator/pkg/options"
+)
+
+const (
+	// JournalTag is the tag prefix of journald records, followed by the systemd unit name
+	JournalTag = "node.journal"
+	// AuditTag is the tag of kube-apiserver audit log records
+	AuditTag = "node.audit"
+)
+
+// nodeLogs describes logs collected from nodes in addition to container logs
+type nodeLogs struct {
+	units    []string
+	auditLog string
+}
+
+func getNodeLogs(cfg *options.Config) nodeLogs {
+	nl := nodeLogs{
+		auditLog: cfg.AuditLog,
+	}
+
+	for _, u := range strings.Split(cfg.JournaldUnits, ",") {
+		if u = strings.TrimSpace(u); len(u) > 0 {
+			nl.units = append(nl.units, u)
+		}
+	}
+
+	return nl
+}
+
+// auditLogDir returns host directory to be mounted for the audit log, if it is not covered by /var/log
+func (nl nodeLogs) auditLogDir() string {
+	if len(nl.auditLog) == 0 {
+		return ""
+	}
+
+	dir := filepath.Dir(nl.auditLog)
+	if rel, err := filepath.Rel(volumePaths["varlog"], dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return ""
+	}
+
+	return dir
+}
+
+// getInputConf returns fluent-bit input configuration for container logs written by the runtime
+// and for enabled node logs
+func getInputConf(rt ContainerRuntime, nl nodeLogs) []byte {
+	var ret bytes.Buffer
+	fmt.Fprintf(&ret, "[INPUT]")
+	fmt.Fprintf(&ret, "\n    Name              tail")
+	fmt.Fprintf(&ret, "\n    Tag               kube.*")
+	fmt.Fprintf(&ret, "\n    Path              /var/log/containers/*.log")
+	switch {
+	case rt.IsCRI():
+		// Built-in cri parser splits the CRI prefix and joins partial (P) lines up to the final (F) one
+		fmt.Fprintf(&ret, "\n    multiline.parser  cri")
+	case rt == Docker:
+		fmt.Fprintf(&ret, "\n    Parser            docker")
+	default:
+		// Each line is parsed with the first built-in parser matching its format
+		fmt.Fprintf(&ret, "\n    multiline.parser  docker, cri")
+	}
+	fmt.Fprintf(&ret, "\n    DB                /db/flb_kube.db")
+	fmt.Fprintf(&ret, "\n    Mem_Buf_Limit     5MB")
+	fmt.Fprintf(&ret, "\n    Skip_Long_Lines   On")
+	fmt.Fprintf(&ret, "\n    Refresh_Interval  10")
+	fmt.Fprintf(&ret, "\n")
+
+	if len(nl.units) > 0 {
+		// Star in the tag is replaced with the unit name by fluent-bit
+		fmt.Fprintf(&ret, "\n[INPUT]")
+		fmt.Fprintf(&ret, "\n    Name              systemd")
+		fmt.Fprintf(&ret, "\n    Tag               %s.*", JournalTag)
+		for _, u := range nl.units {
+			fmt.Fprintf(&ret, "\n    Systemd_Filter    _SYSTEMD_UNIT=%s", u)
+		}
+		fmt.Fprintf(&ret, "\n    DB                /db/flb_journal.db")
+		fmt.Fprintf(&ret, "\n    Read_From_Tail    On")
+		fmt.Fprintf(&ret, "\n    Strip_Underscores On")
+		fmt.Fprintf(&ret, "\n")
+	}
+
+	if len(nl.auditLog) > 0 {
+		// Audit log only exists on control plane nodes, tail waits for it elsewhere
+		fmt.Fprintf(&ret, "\n[INPUT]")
+		fmt.Fprintf(&ret, "\n    Name              tail")
+		fmt.Fprintf(&ret, "\n    Tag               %s", AuditTag)
+		fmt.Fprintf(&ret, "\n    Path              %s", nl.auditLog)
+		fmt.Fprintf(&ret, "\n    Parser            json")
+		fmt.Fprintf(&ret, "\n    DB                /db/flb_audit.db")
+		fmt.Fprintf(&ret, "\n    Mem_Buf_Limit     5MB")
+		fmt.Fprintf(&ret, "\n    Skip_Long_Lines   On")
+		fmt.Fprintf(&ret, "\n    Refresh_Interval  10")
+		fmt.Fprintf(&ret, "\n")
+	}
+
+	return ret.Bytes()
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func getVolumeNames(volumes []corev1.Volume) []string {
+	ret := []string{}
+	for _, v := range volumes {
+		ret = append(ret, v.Name)
+	}
+	return ret
+}
+
+func TestContainerInput(t *testing.T) {
+	docker := string(getInputConf(Docker, nodeLogs{}))
+	assert.Contains(t, docker, "Parser            docker")
+	assert.Equal(t, 1, strings.Count(docker, "[INPUT]"))
+
+	cri := string(getInputConf(Containerd, nodeLogs{}))
+	assert.Contains(t, cri, "multiline.parser  cri")
+	assert.NotContains(t, cri, "Parser            docker")
+
+	assert.Contains(t, getVolumeNames(getVolumes(options.New(), Docker, nodeLogs{})), "varlibdockercontainers")
+	assert.NotContains(t, getVolumeNames(getVolumes(options.New(), CRIO, nodeLogs{})), "varlibdockercontainers")
+
+	// Nodes may run either runtime
+	auto := string(getInputConf(Auto, nodeLogs{}))
+	assert.Contains(t, auto, "multiline.parser  docker, cri")
+	assert.NotContains(t, auto, "Parser            docker")
+	assert.Contains(t, getVolumeNames(getVolumes(options.New(), Auto, nodeLogs{})), "varlibdockercontainers")
+}
+
+func TestParseContainerRuntime(t *testing.T) {
+	for in, expected := range map[string]ContainerRuntime{"docker": Docker, "Containerd": Containerd,
+		"cri-o": CRIO, "auto": Auto} {
+		rt, err := ParseContainerRuntime(in)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, rt)
+	}
+
+	_, err := ParseContainerRuntime("rkt")
+	assert.NotNil(t, err)
+}
+
+func TestNodeLogsInput(t *testing.T) {
+	nl := nodeLogs{
+		units:    []string{"kubelet.service", "containerd.service"},
+		auditLog: "/var/log/kube-apiserver-audit.log",
+	}
+
+	cfg := string(getInputConf(Containerd, nl))
+	assert.Equal(t, 3, strings.Count(cfg, "[INPUT]"))
+	assert.Contains(t, cfg, "Tag               node.journal.*")
+	assert.Contains(t, cfg, "Systemd_Filter    _SYSTEMD_UNIT=kubelet.service")
+	assert.Contains(t, cfg, "Systemd_Filter    _SYSTEMD_UNIT=containerd.service")
+	assert.Contains(t, cfg, "Tag               node.audit")
+	assert.Contains(t, cfg, "Path              /var/log/kube-apiserver-audit.log")
+
+	volumes := getVolumeNames(getVolumes(options.New(), Containerd, nl))
+	assert.Contains(t, volumes, "runlogjournal")
+	assert.Contains(t, volumes, "machineid")
+	// Audit log under /var/log is readable through varlog
+	assert.NotContains(t, volumes, "auditlog")
+	assert.Equal(t, len(volumes), len(getVolumeMounts(options.New(), Containerd, nl)))
+}
+
+func TestAuditLogOutsideVarLog(t *testing.T) {
+	nl := nodeLogs{auditLog: "/etc/kubernetes/audit/audit.log"}
+	assert.Equal(t, "/etc/kubernetes/audit", nl.auditLogDir())
+	assert.Contains(t, getVolumeNames(getVolumes(options.New(), Docker, nl)), "auditlog")
+
+	nl = nodeLogs{auditLog: "/var/logs/audit.log"}
+	assert.Equal(t, "/var/logs", nl.auditLogDir())
+}
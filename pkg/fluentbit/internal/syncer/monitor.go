@@ -0,0 +1,70 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/metrics"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/presslabs/controller-util/syncer"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	metricsPortName = "prometheus"
+	metricsPort     = 2020
+	metricsPath     = "/api/v1/metrics/prometheus"
+)
+
+type fbMonitorSyncer struct {
+	input *unstructured.Unstructured
+}
+
+// NewFluentbitMonitorSyncer returns a sync interface compliant implementation for prometheus-operator
+// PodMonitor scraping fluent-bit pods, which have no service
+func NewFluentbitMonitorSyncer(c client.Client, scheme *runtime.Scheme, cfg *options.Config, owner *v1alpha1.LoggingConfig) syncer.Interface {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(metrics.PodMonitorGVK)
+	obj.SetName("fluent-bit")
+	obj.SetNamespace(cfg.LogNs)
+
+	sync := &fbMonitorSyncer{obj}
+
+	return syncer.NewObjectSyncer("PodMonitor", owner, obj, c, scheme, sync.SyncFn)
+}
+
+// SyncFn syncs the fluent-bit PodMonitor per spec
+func (s *fbMonitorSyncer) SyncFn() error {
+	out := s.input
+	out.SetLabels(Labels)
+
+	matchLabels := map[string]interface{}{}
+	for k, v := range Labels {
+		matchLabels[k] = v
+	}
+
+	return unstructured.SetNestedField(out.Object, map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": matchLabels,
+		},
+		"podMetricsEndpoints": []interface{}{
+			map[string]interface{}{
+				"port": metricsPortName,
+				"path": metricsPath,
+			},
+		},
+	}, "spec")
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/platform9/fluentd-operator/pkg/apis"
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMonitor(t *testing.T) {
+	s := runtime.NewScheme()
+	assert.Nil(t, apis.AddToScheme(s))
+	owner := &v1alpha1.LoggingConfig{ObjectMeta: metav1.ObjectMeta{Name: v1alpha1.LoggingConfigName}}
+
+	m := NewFluentbitMonitorSyncer(fake.NewFakeClient(), s, options.New(), owner)
+	_, err := m.Sync(context.TODO())
+	assert.Nil(t, err)
+
+	obj := m.Object().(*unstructured.Unstructured).Object
+	endpoints, _, _ := unstructured.NestedSlice(obj, "spec", "podMetricsEndpoints")
+	assert.Equal(t, metricsPortName, endpoints[0].(map[string]interface{})["port"])
+	assert.Equal(t, metricsPath, endpoints[0].(map[string]interface{})["path"])
+
+	matchLabels, _, _ := unstructured.NestedStringMap(obj, "spec", "selector", "matchLabels")
+	assert.Equal(t, Labels, matchLabels)
+
+	refs := m.Object().(*unstructured.Unstructured).GetOwnerReferences()
+	assert.Equal(t, v1alpha1.LoggingConfigName, refs[0].Name)
+}
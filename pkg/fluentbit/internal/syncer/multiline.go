@@ -0,0 +1,199 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	multilineParsersFile = "parsers-multiline.conf"
+	multilineFilterFile  = "multiline.conf"
+	startState           = "start_state"
+)
+
+// multilineRule moves the parser from state to next when a line matches regex. Regular expressions
+// must stay within the syntax shared by fluent-bit (Onigmo) and Go (RE2) so they can be unit tested.
+type multilineRule struct {
+	state string
+	regex string
+	next  string
+}
+
+// multilineRules are built-in state machines to join stack traces, keyed by language
+var multilineRules = map[string][]multilineRule{
+	"java": {
+		{startState, `^.*(Exception|Error|Throwable)(: .*)?$`, "java_trace"},
+		{"java_trace", `^\s+at \S+`, "java_trace"},
+		{"java_trace", `^\s+\.\.\. \d+ (more|common frames omitted)$`, "java_trace"},
+		{"java_trace", `^\s*(Caused by|Suppressed): \S+`, "java_trace"},
+	},
+	"python": {
+		{startState, `^Traceback \(most recent call last\):$`, "python_trace"},
+		{"python_trace", `^\s+File `, "python_code"},
+		{"python_code", `^\s+\S`, "python_trace"},
+		{"python_trace", `^([\w]+\.)*[\w]+(: .*)?$`, "python_end"},
+		{"python_end", `^$`, "python_chain"},
+		{"python_chain", `^(During handling|The above exception)`, "python_chain_end"},
+		{"python_chain_end", `^$`, "python_chain_start"},
+		{"python_chain_start", `^Traceback \(most recent call last\):$`, "python_trace"},
+	},
+	"go": {
+		{startState, `^panic: `, "go_panic"},
+		{"go_panic", `^$`, "go_goroutine"},
+		{"go_panic", `^\s*\[recovered\]`, "go_panic"},
+		{"go_panic", `^panic: `, "go_panic"},
+		{"go_goroutine", `^goroutine \d+ \[[^\]]+\]:$`, "go_frame"},
+		{"go_frame", `^created by \S+`, "go_location"},
+		{"go_frame", `^\S.*\)$`, "go_location"},
+		{"go_frame", `^$`, "go_goroutine"},
+		{"go_location", `^\s+\S+:\d+( \+0x[0-9a-f]+)?$`, "go_frame"},
+	},
+	"ruby": {
+		{startState, "^\\S+:\\d+:in [`'][^']*': .*$", "ruby_trace"},
+		{"ruby_trace", "^\\s+from \\S+:\\d+:in [`'][^']*'$", "ruby_trace"},
+	},
+}
+
+// multilineLanguages returns sorted list of languages with built-in stack trace rules
+func multilineLanguages() []string {
+	ret := []string{}
+	for lang := range multilineRules {
+		ret = append(ret, lang)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// containerTag is the tag of container logs, followed by pod_namespace_container-id.log
+const containerTag = "kube.var.log.containers."
+
+// podPattern matches namespace/pod selectors of multiline languages, where pod names may contain * wildcards
+var podPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(/[-.a-z0-9*]+)?$`)
+
+// multiline joins stack traces of languages langs in logs of containers whose tag matches match
+type multiline struct {
+	match string
+	langs []string
+}
+
+// parseMultiline returns multiline filters of comma separated input, verifying rules exist for each language.
+// A language is followed by the namespace and pod name pattern of containers it is limited to, if it isn't
+// enabled for all containers, e.g. java:payments/api-*. Pods are selected by name, as fluent-bit joins lines
+// before the kubernetes filter adds metadata of the pod, such as annotations.
+func parseMultiline(in string) ([]multiline, error) {
+	ret := []multiline{}
+	index := map[string]int{}
+	global := map[string]bool{}
+	for _, entry := range strings.Split(in, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		lang, pods := entry, ""
+		if i := strings.Index(entry, ":"); i >= 0 {
+			lang, pods = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+		lang = strings.ToLower(lang)
+		if _, ok := multilineRules[lang]; !ok {
+			return []multiline{}, fmt.Errorf("Unsupported multiline language %s, valid are: %s", lang,
+				strings.Join(multilineLanguages(), ", "))
+		}
+
+		match := "kube.*"
+		if len(pods) > 0 {
+			if !podPattern.MatchString(pods) {
+				return []multiline{}, fmt.Errorf("Invalid multiline pods %s of %s, expected namespace or namespace/pod", pods, lang)
+			}
+			ns, pod := pods, "*"
+			if i := strings.Index(pods, "/"); i >= 0 {
+				ns, pod = pods[:i], pods[i+1:]
+			}
+			match = fmt.Sprintf("%s%s_%s_*", containerTag, pod, ns)
+		} else {
+			global[lang] = true
+		}
+
+		i, ok := index[match]
+		if !ok {
+			i = len(ret)
+			index[match] = i
+			ret = append(ret, multiline{match: match})
+		}
+		ret[i].langs = append(ret[i].langs, lang)
+	}
+
+	// Languages enabled for all containers aren't repeated for some
+	filters := []multiline{}
+	for _, m := range ret {
+		langs := []string{}
+		seen := map[string]bool{}
+		for _, lang := range m.langs {
+			if seen[lang] || (m.match != "kube.*" && global[lang]) {
+				continue
+			}
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+		if len(langs) > 0 {
+			filters = append(filters, multiline{match: m.match, langs: langs})
+		}
+	}
+
+	return filters, nil
+}
+
+// getMultilineConf returns fluent-bit multiline parsers and the filters which apply them to
+// container logs. Both are empty if no language is enabled.
+func getMultilineConf(filters []multiline) ([]byte, []byte) {
+	var parsers, filter bytes.Buffer
+	defined := map[string]bool{}
+	for _, m := range filters {
+		names := []string{}
+		for _, lang := range m.langs {
+			name := fmt.Sprintf("multiline-%s", lang)
+			names = append(names, name)
+			if defined[lang] {
+				continue
+			}
+			defined[lang] = true
+
+			fmt.Fprintf(&parsers, "[MULTILINE_PARSER]")
+			fmt.Fprintf(&parsers, "\n    name          %s", name)
+			fmt.Fprintf(&parsers, "\n    type          regex")
+			fmt.Fprintf(&parsers, "\n    flush_timeout 1000")
+			for _, r := range multilineRules[lang] {
+				fmt.Fprintf(&parsers, "\n    rule          \"%s\" \"/%s/\" \"%s\"", r.state, r.regex, r.next)
+			}
+			fmt.Fprintf(&parsers, "\n\n")
+		}
+
+		if filter.Len() > 0 {
+			fmt.Fprintf(&filter, "\n")
+		}
+		fmt.Fprintf(&filter, "[FILTER]")
+		fmt.Fprintf(&filter, "\n    Name                  multiline")
+		fmt.Fprintf(&filter, "\n    Match                 %s", m.match)
+		fmt.Fprintf(&filter, "\n    multiline.key_content log")
+		fmt.Fprintf(&filter, "\n    multiline.parser      %s", strings.Join(names, ", "))
+		fmt.Fprintf(&filter, "\n")
+	}
+
+	return parsers.Bytes(), filter.Bytes()
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const javaTrace = `Exception in thread "main" java.lang.IllegalStateException: could not start
+	at com.example.App.start(App.java:42)
+	at com.example.App.main(App.java:12)
+Caused by: java.lang.NullPointerException: config is null
+	at com.example.Config.load(Config.java:7)
+	... 2 more`
+
+const pythonTrace = `Traceback (most recent call last):
+  File "/app/main.py", line 10, in <module>
+    main()
+  File "/app/main.py", line 6, in main
+    raise ValueError("bad input")
+ValueError: bad input`
+
+const goTrace = `panic: runtime error: index out of range [3] with length 3
+
+goroutine 1 [running]:
+main.lookup(...)
+	/app/main.go:8
+main.main()
+	/app/main.go:13 +0x1d`
+
+const rubyTrace = "app.rb:2:in `parse': invalid input (ArgumentError)\n" +
+	"\tfrom app.rb:6:in `load'\n" +
+	"\tfrom app.rb:9:in `<main>'"
+
+// join groups lines the way a fluent-bit multiline parser does. A group begins when a start_state
+// rule matches and grows while a rule of the current state matches the next line.
+func join(t *testing.T, lang string, lines []string) []string {
+	rules := map[string][]multilineRule{}
+	for _, r := range multilineRules[lang] {
+		_, err := regexp.Compile(r.regex)
+		assert.Nil(t, err)
+		rules[r.state] = append(rules[r.state], r)
+	}
+
+	next := func(state, line string) (string, bool) {
+		for _, r := range rules[state] {
+			if regexp.MustCompile(r.regex).MatchString(line) {
+				return r.next, true
+			}
+		}
+		return "", false
+	}
+
+	ret := []string{}
+	var group []string
+	state := startState
+	for _, line := range lines {
+		if state != startState {
+			if s, ok := next(state, line); ok {
+				group = append(group, line)
+				state = s
+				continue
+			}
+			ret = append(ret, strings.Join(group, "\n"))
+			group, state = nil, startState
+		}
+
+		if s, ok := next(startState, line); ok {
+			group = []string{line}
+			state = s
+			continue
+		}
+		ret = append(ret, line)
+	}
+
+	if len(group) > 0 {
+		ret = append(ret, strings.Join(group, "\n"))
+	}
+
+	return ret
+}
+
+func TestMultilineTraces(t *testing.T) {
+	traces := map[string]string{
+		"java":   javaTrace,
+		"python": pythonTrace,
+		"go":     goTrace,
+		"ruby":   rubyTrace,
+	}
+
+	for lang, trace := range traces {
+		lines := []string{"starting server"}
+		lines = append(lines, strings.Split(trace, "\n")...)
+		lines = append(lines, "server stopped")
+
+		records := join(t, lang, lines)
+		assert.Equal(t, []string{"starting server", trace, "server stopped"}, records, lang)
+	}
+}
+
+func TestMultilineKeepsPlainLines(t *testing.T) {
+	lines := []string{"GET /healthz 200", "  indented line", "Error connecting to db, retrying"}
+	for _, lang := range multilineLanguages() {
+		assert.Equal(t, lines, join(t, lang, lines), lang)
+	}
+}
+
+func TestParseMultiline(t *testing.T) {
+	filters, err := parseMultiline(" Java,python,,")
+	assert.Nil(t, err)
+	assert.Equal(t, []multiline{{match: "kube.*", langs: []string{"java", "python"}}}, filters)
+
+	// Languages may be limited to pods of a namespace, by name
+	filters, err = parseMultiline("java:payments/api-*,go:payments/api-*,python:jobs,go,ruby:payments")
+	assert.Nil(t, err)
+	assert.Equal(t, []multiline{
+		{match: "kube.var.log.containers.api-*_payments_*", langs: []string{"java"}},
+		{match: "kube.var.log.containers.*_jobs_*", langs: []string{"python"}},
+		{match: "kube.*", langs: []string{"go"}},
+		{match: "kube.var.log.containers.*_payments_*", langs: []string{"ruby"}},
+	}, filters)
+
+	for _, in := range []string{"java,cobol", "java:Payments", "java:payments/api_*", "java:/api"} {
+		_, err = parseMultiline(in)
+		assert.NotNil(t, err, in)
+	}
+}
+
+func TestMultilineConf(t *testing.T) {
+	parsers, filter := getMultilineConf([]multiline{})
+	assert.Empty(t, parsers)
+	assert.Empty(t, filter)
+
+	parsers, filter = getMultilineConf([]multiline{{match: "kube.*", langs: []string{"java", "go"}}})
+	assert.Equal(t, 2, strings.Count(string(parsers), "[MULTILINE_PARSER]"))
+	assert.Contains(t, string(parsers), "name          multiline-java")
+	assert.Contains(t, string(filter), "multiline.parser      multiline-java, multiline-go")
+
+	// Parsers are defined once for filters of several pods
+	filters, err := parseMultiline("java:payments/api-*,java:jobs")
+	assert.Nil(t, err)
+	parsers, filter = getMultilineConf(filters)
+	assert.Equal(t, 1, strings.Count(string(parsers), "[MULTILINE_PARSER]"))
+	assert.Equal(t, 2, strings.Count(string(filter), "[FILTER]"))
+	assert.Contains(t, string(filter), "Match                 kube.var.log.containers.api-*_payments_*")
+	assert.Contains(t, string(filter), "Match                 kube.var.log.containers.*_jobs_*")
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/platform9/fluentd-operator/pkg/certs"
+	"github.com/platform9/fluentd-operator/pkg/options"
+)
+
+// fluentd service in the same namespace
+const forwardHost = "fluentd"
+
+// getOutputConf returns fluent-bit output configuration forwarding all records to fluentd. When secure,
+// fluentd is verified against the operator CA and the shared key is presented during handshake.
+func getOutputConf(cfg *options.Config) []byte {
+	var ret bytes.Buffer
+	fmt.Fprintf(&ret, "[OUTPUT]")
+	fmt.Fprintf(&ret, "\n    Name              forward")
+	fmt.Fprintf(&ret, "\n    Match             *")
+	fmt.Fprintf(&ret, "\n    Host              %s", forwardHost)
+	fmt.Fprintf(&ret, "\n    Port              %d", cfg.ForwardPort)
+	if cfg.SecureForward {
+		fmt.Fprintf(&ret, "\n    tls               On")
+		fmt.Fprintf(&ret, "\n    tls.verify        On")
+		fmt.Fprintf(&ret, "\n    tls.ca_file       %s/%s", certs.FluentbitMountPath, certs.CACertKey)
+		fmt.Fprintf(&ret, "\n    Shared_Key        ${%s}", certs.SharedKeyEnv)
+		fmt.Fprintf(&ret, "\n    Self_Hostname     ${NODE_NAME}")
+	}
+	fmt.Fprintf(&ret, "\n")
+
+	return ret.Bytes()
+}
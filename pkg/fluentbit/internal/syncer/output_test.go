@@ -0,0 +1,47 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"testing"
+
+	"github.com/platform9/fluentd-operator/pkg/certs"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutput(t *testing.T) {
+	cfg := options.New()
+	plain := string(getOutputConf(cfg))
+	assert.Contains(t, plain, "Host              fluentd")
+	assert.Contains(t, plain, "Port              62073")
+	assert.NotContains(t, plain, "tls")
+
+	cfg.SecureForward = true
+	secure := string(getOutputConf(cfg))
+	assert.Contains(t, secure, "tls               On")
+	assert.Contains(t, secure, "tls.ca_file       /fluent-bit/tls/ca.crt")
+	assert.Contains(t, secure, "Shared_Key        ${FLUENT_FORWARD_SHARED_KEY}")
+}
+
+func TestSecureVolumes(t *testing.T) {
+	cfg := options.New()
+	assert.NotContains(t, getVolumeNames(getVolumes(cfg, Docker, nodeLogs{})), certs.ClientSecret)
+
+	cfg.SecureForward = true
+	assert.Contains(t, getVolumeNames(getVolumes(cfg, Docker, nodeLogs{})), certs.ClientSecret)
+	env := getEnv(cfg)
+	assert.Equal(t, certs.SharedKeyEnv, env[0].Name)
+	assert.Equal(t, certs.ClientSecret, env[0].ValueFrom.SecretKeyRef.Name)
+}
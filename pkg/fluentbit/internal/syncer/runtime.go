@@ -0,0 +1,49 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContainerRuntime identifies the container runtime whose log files fluent-bit tails
+type ContainerRuntime string
+
+const (
+	// Docker writes json-file logs under /var/lib/docker/containers
+	Docker ContainerRuntime = "docker"
+	// Containerd writes CRI formatted logs under /var/log/pods
+	Containerd ContainerRuntime = "containerd"
+	// CRIO writes CRI formatted logs under /var/log/pods
+	CRIO ContainerRuntime = "cri-o"
+	// Auto reads logs of any of them, so that nodes can run different runtimes with a single daemonset
+	Auto ContainerRuntime = "auto"
+)
+
+// ParseContainerRuntime maps a runtime name to a known container runtime
+func ParseContainerRuntime(s string) (ContainerRuntime, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	switch ContainerRuntime(name) {
+	case Docker, Containerd, CRIO, Auto:
+		return ContainerRuntime(name), nil
+	}
+
+	return "", fmt.Errorf("Unsupported container runtime: %s", s)
+}
+
+// IsCRI returns true if the runtime writes logs in CRI format: "<time> <stream> <P|F> <log>"
+func (rt ContainerRuntime) IsCRI() bool {
+	return rt == Containerd || rt == CRIO
+}
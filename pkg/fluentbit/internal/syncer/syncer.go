@@ -14,9 +14,13 @@ limitations under the License.
 package syncer
 
 import (
+	"strconv"
+
 	"github.com/presslabs/controller-util/mergo/transformers"
 
 	"github.com/imdario/mergo"
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/certs"
 	"github.com/platform9/fluentd-operator/pkg/options"
 	"github.com/platform9/fluentd-operator/pkg/utils"
 	"github.com/presslabs/controller-util/syncer"
@@ -27,10 +31,13 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
+	// Name of fluent-bit daemonset
+	Name       = "fluent-bit"
 	cfgMapName = "fluent-bit-config"
 )
 
@@ -44,57 +51,76 @@ var Labels = map[string]string{
 var volumePaths = map[string]string{
 	"varlog":                 "/var/log",
 	"varlibdockercontainers": "/var/lib/docker/containers",
+	"runlogjournal":          "/run/log/journal",
+	"machineid":              "/etc/machine-id",
 	cfgMapName:               "/fluent-bit/etc/",
+	certs.ClientSecret:       certs.FluentbitMountPath,
 }
 
 type fbSyncer struct {
-	input runtime.Object
+	input       runtime.Object
+	cfg         *options.Config
+	runtime     ContainerRuntime
+	spec        v1alpha1.ComponentSpec
+	tlsChecksum string
 }
 
 type fbCfgMapSyncer struct {
-	input runtime.Object
+	input    runtime.Object
+	cfg      *options.Config
+	runtime  ContainerRuntime
+	recorder record.EventRecorder
 }
 
-// NewFluentbitSyncer returns a sync interface compliant implementation for fluentbit
-func NewFluentbitSyncer(c client.Client, scheme *runtime.Scheme) syncer.Interface {
+// NewFluentbitSyncer returns a sync interface compliant implementation for fluentbit. owner customizes the pods,
+// tlsChecksum is the checksum of forward client secret when secure forward is enabled.
+func NewFluentbitSyncer(c client.Client, scheme *runtime.Scheme, cfg *options.Config, owner *v1alpha1.LoggingConfig,
+	rt ContainerRuntime, tlsChecksum string) syncer.Interface {
 	obj := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "fluent-bit",
-			Namespace: *(options.LogNs),
+			Name:      Name,
+			Namespace: cfg.LogNs,
 		},
 	}
 
-	sync := &fbSyncer{obj}
+	sync := &fbSyncer{obj, cfg, rt, owner.Spec.Fluentbit, tlsChecksum}
 
-	return syncer.NewObjectSyncer("DaemonSet", nil, obj, c, scheme, sync.SyncFn)
+	return syncer.NewObjectSyncer("DaemonSet", owner, obj, c, scheme, sync.SyncFn)
 }
 
-// NewFluentbitCfgMapSyncer returns a sync interface compliant implementation for fluentbit configmap
-func NewFluentbitCfgMapSyncer(c client.Client, scheme *runtime.Scheme) syncer.Interface {
+// NewFluentbitCfgMapSyncer returns a sync interface compliant implementation for fluentbit configmap. Edits
+// outside of operator are reported with e and reverted.
+func NewFluentbitCfgMapSyncer(c client.Client, scheme *runtime.Scheme, e record.EventRecorder, cfg *options.Config,
+	owner *v1alpha1.LoggingConfig, rt ContainerRuntime) syncer.Interface {
 	obj := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cfgMapName,
-			Namespace: *(options.LogNs),
+			Namespace: cfg.LogNs,
 		},
 	}
 
-	sync := &fbCfgMapSyncer{obj}
+	sync := &fbCfgMapSyncer{obj, cfg, rt, e}
 
-	return syncer.NewObjectSyncer("ConfigMap", nil, obj, c, scheme, sync.SyncFn)
+	return syncer.NewObjectSyncer("ConfigMap", owner, obj, c, scheme, sync.SyncFn)
 }
 
 // SyncFn syncs the Fluentbit config map per spec
 func (s *fbCfgMapSyncer) SyncFn() error {
 	out := s.input.(*corev1.ConfigMap)
 	out.ObjectMeta.Labels = Labels
-	if len(out.Data) == 0 {
-		if d, err := utils.GetCfgMapData("fluent-bit"); err != nil {
-			return err
-		} else {
-			out.BinaryData = d
-		}
+	d, err := utils.GetCfgMapData(s.cfg.CfgDir, "fluent-bit")
+	if err != nil {
+		return err
 	}
-	return nil
+	d["input.conf"] = getInputConf(s.runtime, getNodeLogs(s.cfg))
+	d["output.conf"] = getOutputConf(s.cfg)
+
+	filters, err := parseMultiline(s.cfg.Multiline)
+	if err != nil {
+		return err
+	}
+	d[multilineParsersFile], d[multilineFilterFile] = getMultilineConf(filters)
+	return utils.SyncCfgMapData(s.recorder, out, d)
 }
 
 func getLabels() labels.Set {
@@ -105,8 +131,8 @@ func getLabels() labels.Set {
 func (s *fbSyncer) SyncFn() error {
 	annotations := map[string]string{
 		"prometheus.io/scrape": "true",
-		"prometheus.io/port":   "2020",
-		"prometheus.io/path":   "/api/v1/metrics/prometheus",
+		"prometheus.io/port":   strconv.Itoa(metricsPort),
+		"prometheus.io/path":   metricsPath,
 	}
 
 	out := s.input.(*appsv1.DaemonSet)
@@ -134,10 +160,24 @@ func (s *fbSyncer) SyncFn() error {
 		out.Spec.Template.ObjectMeta.Annotations[k] = v
 	}
 
-	return mergo.Merge(&out.Spec.Template.Spec, getPodSpec(), mergo.WithTransformers(transformers.PodSpec))
+	if len(s.tlsChecksum) > 0 {
+		out.Spec.Template.ObjectMeta.Annotations[certs.ChecksumAnnotation] = s.tlsChecksum
+	}
+
+	podSpec := getPodSpec(s.cfg, s.runtime, getNodeLogs(s.cfg))
+	utils.ApplyComponentSpec(&podSpec, s.spec)
+
+	if err := mergo.Merge(&out.Spec.Template.Spec, podSpec, mergo.WithTransformers(transformers.PodSpec)); err != nil {
+		return err
+	}
+
+	// Merge keeps existing values, these can be unset in LoggingConfig
+	out.Spec.Template.Spec.NodeSelector = podSpec.NodeSelector
+	out.Spec.Template.Spec.PriorityClassName = podSpec.PriorityClassName
+	return nil
 }
 
-func getPodSpec() corev1.PodSpec {
+func getPodSpec(cfg *options.Config, rt ContainerRuntime, nl nodeLogs) corev1.PodSpec {
 	return corev1.PodSpec{
 		Tolerations: []corev1.Toleration{
 			{
@@ -148,29 +188,26 @@ func getPodSpec() corev1.PodSpec {
 		Containers: []corev1.Container{
 			{
 				Name:            "fluent-bit",
-				Image:           *(options.FluentbitImage),
+				Image:           cfg.FluentbitImage,
 				ImagePullPolicy: "IfNotPresent",
 				Ports: []corev1.ContainerPort{{
-					Name:          "prometheus", // TODO: customize
-					ContainerPort: 2020,
+					Name:          metricsPortName,
+					ContainerPort: metricsPort,
 				}},
+				Env: getEnv(cfg),
 				ReadinessProbe: &corev1.Probe{
 					Handler: corev1.Handler{
 						HTTPGet: &corev1.HTTPGetAction{
-							Path: "/api/v1/metrics/prometheus",
-							Port: intstr.IntOrString{
-								IntVal: 2020,
-							},
+							Path: metricsPath,
+							Port: intstr.FromInt(metricsPort),
 						},
 					},
 				},
 				LivenessProbe: &corev1.Probe{
 					Handler: corev1.Handler{
 						HTTPGet: &corev1.HTTPGetAction{
-							Path: "/api/v1/metrics/prometheus",
-							Port: intstr.IntOrString{
-								IntVal: 2020,
-							},
+							Path: metricsPath,
+							Port: intstr.FromInt(metricsPort),
 						},
 					},
 				},
@@ -184,24 +221,44 @@ func getPodSpec() corev1.PodSpec {
 						"memory": resource.MustParse("60Mi"),
 					},
 				},
-				VolumeMounts: getVolumeMounts(),
+				VolumeMounts: getVolumeMounts(cfg, rt, nl),
 			},
 		},
-		Volumes:            getVolumes(),
-		ServiceAccountName: *(options.SvcAcct),
+		Volumes:            getVolumes(cfg, rt, nl),
+		ServiceAccountName: cfg.SvcAcct,
 	}
 }
 
-func getVolumeMounts() []corev1.VolumeMount {
-	return []corev1.VolumeMount{
+func getEnv(cfg *options.Config) []corev1.EnvVar {
+	if !cfg.SecureForward {
+		return nil
+	}
+
+	return []corev1.EnvVar{
 		{
-			Name:      "varlog",
-			MountPath: volumePaths["varlog"],
-			ReadOnly:  true,
+			Name: certs.SharedKeyEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: certs.ClientSecret},
+					Key:                  certs.SharedKeyKey,
+				},
+			},
 		},
 		{
-			Name:      "varlibdockercontainers",
-			MountPath: volumePaths["varlibdockercontainers"],
+			// Identifies the node to fluentd during handshake
+			Name: "NODE_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+			},
+		},
+	}
+}
+
+func getVolumeMounts(cfg *options.Config, rt ContainerRuntime, nl nodeLogs) []corev1.VolumeMount {
+	mounts := []corev1.VolumeMount{
+		{
+			Name:      "varlog",
+			MountPath: volumePaths["varlog"],
 			ReadOnly:  true,
 		},
 		{
@@ -213,10 +270,52 @@ func getVolumeMounts() []corev1.VolumeMount {
 			MountPath: "/db/",
 		},
 	}
+
+	// CRI runtimes keep log files under /var/log/pods, which is covered by varlog. Docker only
+	// symlinks them from there to its own data directory, which is mounted unless no node runs docker.
+	if !rt.IsCRI() {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      "varlibdockercontainers",
+			MountPath: volumePaths["varlibdockercontainers"],
+			ReadOnly:  true,
+		})
+	}
+
+	// Persistent journal lives under /var/log/journal, volatile one under /run. Journal directories
+	// are named after the host's machine id.
+	if len(nl.units) > 0 {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      "runlogjournal",
+			MountPath: volumePaths["runlogjournal"],
+			ReadOnly:  true,
+		}, corev1.VolumeMount{
+			Name:      "machineid",
+			MountPath: volumePaths["machineid"],
+			ReadOnly:  true,
+		})
+	}
+
+	if dir := nl.auditLogDir(); len(dir) > 0 {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      "auditlog",
+			MountPath: dir,
+			ReadOnly:  true,
+		})
+	}
+
+	if cfg.SecureForward {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      certs.ClientSecret,
+			MountPath: volumePaths[certs.ClientSecret],
+			ReadOnly:  true,
+		})
+	}
+
+	return mounts
 }
 
-func getVolumes() []corev1.Volume {
-	return []corev1.Volume{
+func getVolumes(cfg *options.Config, rt ContainerRuntime, nl nodeLogs) []corev1.Volume {
+	volumes := []corev1.Volume{
 		{
 			Name: "varlog",
 			VolumeSource: corev1.VolumeSource{
@@ -225,14 +324,6 @@ func getVolumes() []corev1.Volume {
 				},
 			},
 		},
-		{
-			Name: "varlibdockercontainers",
-			VolumeSource: corev1.VolumeSource{
-				HostPath: &corev1.HostPathVolumeSource{
-					Path: volumePaths["varlibdockercontainers"],
-				},
-			},
-		},
 		{
 			Name: cfgMapName,
 			VolumeSource: corev1.VolumeSource{
@@ -250,4 +341,59 @@ func getVolumes() []corev1.Volume {
 			},
 		},
 	}
+
+	if !rt.IsCRI() {
+		volumes = append(volumes, corev1.Volume{
+			Name: "varlibdockercontainers",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: volumePaths["varlibdockercontainers"],
+				},
+			},
+		})
+	}
+
+	if len(nl.units) > 0 {
+		fileType := corev1.HostPathFile
+		volumes = append(volumes, corev1.Volume{
+			Name: "runlogjournal",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: volumePaths["runlogjournal"],
+				},
+			},
+		}, corev1.Volume{
+			Name: "machineid",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: volumePaths["machineid"],
+					Type: &fileType,
+				},
+			},
+		})
+	}
+
+	if dir := nl.auditLogDir(); len(dir) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: "auditlog",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: dir,
+				},
+			},
+		})
+	}
+
+	if cfg.SecureForward {
+		volumes = append(volumes, corev1.Volume{
+			Name: certs.ClientSecret,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: certs.ClientSecret,
+				},
+			},
+		})
+	}
+
+	return volumes
 }
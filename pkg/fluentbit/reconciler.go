@@ -21,13 +21,18 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/go-logr/logr"
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/cache"
 	fbsyncer "github.com/platform9/fluentd-operator/pkg/fluentbit/internal/syncer"
+	"github.com/platform9/fluentd-operator/pkg/metrics"
 	"github.com/platform9/fluentd-operator/pkg/options"
 	"github.com/platform9/fluentd-operator/pkg/utils"
 	"github.com/presslabs/controller-util/syncer"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -37,35 +42,50 @@ var log = logf.Log.WithName("fluentbit_reconciler")
 
 // Reconciler reconciles fluentbit daemonset
 type Reconciler struct {
-	// This client, initialized using mgr.Client() above, is a split client
-	// that reads objects from the cache and writes to the apiserver
+	// This client is a split client that reads objects from the cache of logging namespace and writes to
+	// the apiserver
 	client   client.Client
 	scheme   *runtime.Scheme
 	recorder record.EventRecorder
+	mapper   meta.RESTMapper
+	cfg      *options.Config
 }
 
 // New returns new instance of reconciler
-func New(mgr manager.Manager) *Reconciler {
+func New(mgr manager.Manager, cfg *options.Config) (*Reconciler, error) {
+	c, err := cache.Get(mgr, cfg.LogNs)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Reconciler{
-		client:   mgr.GetClient(),
+		client:   c.Client,
 		scheme:   mgr.GetScheme(),
 		recorder: mgr.GetEventRecorderFor("controller.fluentbit"),
-	}
+		mapper:   mgr.GetRESTMapper(),
+		cfg:      cfg,
+	}, nil
+}
+
+// Request returns the reconcile request of LoggingConfig, which owns fluent-bit daemonset and all objects managed
+// for it. Reconciling it syncs them all.
+func (r *Reconciler) Request() reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Name: v1alpha1.LoggingConfigName}}
 }
 
 // Reconcile reads state of cluster for DaemonSet objects and makes
 // changes per how the controller definition needs to be
 func (r *Reconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
-	// TODO: Watch daemonsets only in current namespace
 	instance := &appsv1.DaemonSet{}
 
-	// Only interested in configured namespace for fluentbit daemonset
-	if request.Namespace != *(options.LogNs) {
+	// Objects managed for fluent-bit are enqueued by their owner
+	if request != r.Request() {
 		return reconcile.Result{}, nil
 	}
 
-	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	key := types.NamespacedName{Namespace: r.cfg.LogNs, Name: fbsyncer.Name}
+	err := r.client.Get(context.TODO(), key, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return reconcile.Result{}, r.CreateIfNeeded()
@@ -86,8 +106,23 @@ func (r *Reconciler) reconcile(reqLogger logr.Logger, current *appsv1.DaemonSet)
 		return reconcile.Result{}, nil
 	}
 
-	syncers := []syncer.Interface{fbsyncer.NewFluentbitSyncer(r.client, r.scheme),
-		fbsyncer.NewFluentbitCfgMapSyncer(r.client, r.scheme),
+	rt, err := fbsyncer.ParseContainerRuntime(r.cfg.ContainerRuntime)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	checksum, err := getTLSChecksum(r.client, r.cfg)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	lc, err := utils.EnsureLoggingConfig(r.client)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	syncers := []syncer.Interface{fbsyncer.NewFluentbitSyncer(r.client, r.scheme, r.cfg, lc, rt, checksum),
+		fbsyncer.NewFluentbitCfgMapSyncer(r.client, r.scheme, r.recorder, r.cfg, lc, rt),
 	}
 
 	for _, sync := range syncers {
@@ -96,13 +131,28 @@ func (r *Reconciler) reconcile(reqLogger logr.Logger, current *appsv1.DaemonSet)
 		}
 	}
 
-	return reconcile.Result{}, nil
+	return reconcile.Result{}, r.syncMonitor(lc)
 }
 
 // CreateIfNeeded creates fluentbit daemonset if needed
 func (r *Reconciler) CreateIfNeeded() error {
-	syncers := []syncer.Interface{fbsyncer.NewFluentbitSyncer(r.client, r.scheme),
-		fbsyncer.NewFluentbitCfgMapSyncer(r.client, r.scheme),
+	rt, err := fbsyncer.ParseContainerRuntime(r.cfg.ContainerRuntime)
+	if err != nil {
+		return err
+	}
+
+	checksum, err := getTLSChecksum(r.client, r.cfg)
+	if err != nil {
+		return err
+	}
+
+	lc, err := utils.EnsureLoggingConfig(r.client)
+	if err != nil {
+		return err
+	}
+
+	syncers := []syncer.Interface{fbsyncer.NewFluentbitSyncer(r.client, r.scheme, r.cfg, lc, rt, checksum),
+		fbsyncer.NewFluentbitCfgMapSyncer(r.client, r.scheme, r.recorder, r.cfg, lc, rt),
 	}
 
 	for _, sync := range syncers {
@@ -114,5 +164,15 @@ func (r *Reconciler) CreateIfNeeded() error {
 			return err
 		}
 	}
-	return nil
+	return r.syncMonitor(lc)
+}
+
+// syncMonitor creates PodMonitor of prometheus-operator, if it is installed
+func (r *Reconciler) syncMonitor(lc *v1alpha1.LoggingConfig) error {
+	ok, err := metrics.HasKind(r.mapper, metrics.PodMonitorGVK)
+	if !ok {
+		return err
+	}
+
+	return syncer.Sync(context.TODO(), fbsyncer.NewFluentbitMonitorSyncer(r.client, r.scheme, r.cfg, lc), r.recorder)
 }
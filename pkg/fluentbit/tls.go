@@ -0,0 +1,44 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluentbit
+
+import (
+	"context"
+
+	"github.com/platform9/fluentd-operator/pkg/certs"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getTLSChecksum returns checksum of forward client secret, which is managed along with fluentd. It is empty
+// if secure forward is disabled or the secret does not exist yet, in which case pods wait for it to appear.
+func getTLSChecksum(c client.Client, cfg *options.Config) (string, error) {
+	if !cfg.SecureForward {
+		return "", nil
+	}
+
+	secret := &corev1.Secret{}
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: cfg.LogNs, Name: certs.ClientSecret}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return certs.Checksum(secret), nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/metrics"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/pkg/resources"
+	"github.com/presslabs/controller-util/syncer"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type fdMonitorSyncer struct {
+	input *unstructured.Unstructured
+}
+
+// NewFluentdMonitorSyncer returns a sync interface compliant implementation for prometheus-operator
+// ServiceMonitor scraping fluentd service
+func NewFluentdMonitorSyncer(c client.Client, scheme *runtime.Scheme, cfg *options.Config, owner *v1alpha1.LoggingConfig) syncer.Interface {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(metrics.ServiceMonitorGVK)
+	obj.SetName(svcName)
+	obj.SetNamespace(cfg.LogNs)
+
+	sync := &fdMonitorSyncer{obj}
+
+	return syncer.NewObjectSyncer("ServiceMonitor", owner, obj, c, scheme, sync.SyncFn)
+}
+
+// SyncFn syncs the fluentd ServiceMonitor per spec
+func (s *fdMonitorSyncer) SyncFn() error {
+	out := s.input
+	out.SetLabels(Labels)
+
+	matchLabels := map[string]interface{}{}
+	for k, v := range Labels {
+		matchLabels[k] = v
+	}
+
+	return unstructured.SetNestedField(out.Object, map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": matchLabels,
+		},
+		"endpoints": []interface{}{
+			map[string]interface{}{
+				"port": MetricsPortName,
+				"path": resources.MetricsPath,
+			},
+		},
+	}, "spec")
+}
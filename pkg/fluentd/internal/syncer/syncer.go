@@ -14,8 +14,14 @@ limitations under the License.
 package syncer
 
 import (
+	"bytes"
+	"strconv"
+
 	"github.com/imdario/mergo"
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/certs"
 	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/pkg/resources"
 	"github.com/platform9/fluentd-operator/pkg/utils"
 	"github.com/presslabs/controller-util/mergo/transformers"
 	"github.com/presslabs/controller-util/syncer"
@@ -26,6 +32,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
@@ -33,12 +40,19 @@ import (
 var log = logf.Log.WithName("fluentd_syncer")
 
 const (
+	// Name of fluentd deployment
+	Name       = "fluentd"
 	cfgMapName = "fluentd-config"
 	svcName    = "fluentd"
+	// filesName is the secret holding files referenced by outputs, which may be credentials
+	filesName = "fluentd-files"
+	// MetricsPortName names fluentd metrics port of the pod and service
+	MetricsPortName = "metrics"
 )
 
 var volumePaths = map[string]string{
-	cfgMapName: "/fluentd/etc/",
+	cfgMapName:         "/fluentd/etc/",
+	certs.ServerSecret: certs.FluentdMountPath,
 }
 
 // Labels defines operator enforced labels for fluentd deployment
@@ -49,11 +63,21 @@ var Labels = map[string]string{
 }
 
 type fdSyncer struct {
-	input runtime.Object
+	input       runtime.Object
+	cfg         *options.Config
+	spec        v1alpha1.ComponentSpec
+	tlsChecksum string
 }
 
 type fdCfgMapSyncer struct {
-	data  []byte
+	data     map[string][]byte
+	input    runtime.Object
+	cfg      *options.Config
+	recorder record.EventRecorder
+}
+
+type fdFilesSyncer struct {
+	data  map[string][]byte
 	input runtime.Object
 }
 
@@ -65,59 +89,96 @@ func getLabels() labels.Set {
 	return Labels
 }
 
-// NewFluentdSyncer returns a sync interface compliant implementation for fluentd
-func NewFluentdSyncer(c client.Client, scheme *runtime.Scheme) syncer.Interface {
+// NewFluentdSyncer returns a sync interface compliant implementation for fluentd. owner customizes the pods,
+// tlsChecksum is the checksum of forward certificates when secure forward is enabled.
+func NewFluentdSyncer(c client.Client, scheme *runtime.Scheme, cfg *options.Config, owner *v1alpha1.LoggingConfig,
+	tlsChecksum string) syncer.Interface {
 	obj := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "fluentd",
-			Namespace: *(options.LogNs),
+			Name:      Name,
+			Namespace: cfg.LogNs,
 		},
 	}
 
-	sync := &fdSyncer{obj}
+	sync := &fdSyncer{obj, cfg, owner.Spec.Fluentd, tlsChecksum}
 
-	return syncer.NewObjectSyncer("Deployment", nil, obj, c, scheme, sync.SyncFn)
+	return syncer.NewObjectSyncer("Deployment", owner, obj, c, scheme, sync.SyncFn)
 }
 
-// NewFluentdCfgMapSyncer returns a sync interface compliant implementation for fluentd configmap
-func NewFluentdCfgMapSyncer(c client.Client, scheme *runtime.Scheme, params ...[]byte) syncer.Interface {
+// NewFluentdCfgMapSyncer returns a sync interface compliant implementation for fluentd configmap. Its data is
+// the configuration file of files passed in params, by name, or the default configuration dropping all records.
+// Edits outside of operator are reported with e and reverted.
+func NewFluentdCfgMapSyncer(c client.Client, scheme *runtime.Scheme, e record.EventRecorder, cfg *options.Config,
+	owner *v1alpha1.LoggingConfig, params ...map[string][]byte) syncer.Interface {
 	obj := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cfgMapName,
-			Namespace: *(options.LogNs),
+			Namespace: cfg.LogNs,
 		},
 	}
 
 	sync := &fdCfgMapSyncer{
-		input: obj,
+		input:    obj,
+		cfg:      cfg,
+		recorder: e,
 	}
 
 	if len(params) > 0 {
 		sync.data = params[0]
 	}
 
-	return syncer.NewObjectSyncer("ConfigMap", nil, obj, c, scheme, sync.SyncFn)
+	return syncer.NewObjectSyncer("ConfigMap", owner, obj, c, scheme, sync.SyncFn)
+}
+
+// NewFluentdFilesSyncer returns a sync interface compliant implementation for the secret of files referenced by
+// outputs, such as TLS keys. Its data is the files of configuration other than the configuration file, by name.
+func NewFluentdFilesSyncer(c client.Client, scheme *runtime.Scheme, cfg *options.Config, owner *v1alpha1.LoggingConfig,
+	files map[string][]byte) syncer.Interface {
+	obj := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      filesName,
+			Namespace: cfg.LogNs,
+		},
+	}
+
+	sync := &fdFilesSyncer{
+		data:  map[string][]byte{},
+		input: obj,
+	}
+	for name, data := range files {
+		if name != resources.ConfigFile {
+			sync.data[name] = data
+		}
+	}
+
+	return syncer.NewObjectSyncer("Secret", owner, obj, c, scheme, sync.SyncFn)
 }
 
 // NewFluentdSvcSyncer returns a sync interface compliant implementation for fluentd service
-func NewFluentdSvcSyncer(c client.Client, scheme *runtime.Scheme) syncer.Interface {
+func NewFluentdSvcSyncer(c client.Client, scheme *runtime.Scheme, cfg *options.Config, owner *v1alpha1.LoggingConfig) syncer.Interface {
 	obj := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{Name: svcName,
-			Namespace: *(options.LogNs),
+			Namespace: cfg.LogNs,
 		},
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
 				corev1.ServicePort{
 					Name:       "forwarder",
-					Port:       62073,
+					Port:       int32(cfg.ForwardPort),
 					Protocol:   corev1.ProtocolTCP,
-					TargetPort: intstr.FromInt(62073),
+					TargetPort: intstr.FromInt(cfg.ForwardPort),
 				},
 				corev1.ServicePort{
 					Name:       "webhook",
-					Port:       45550,
+					Port:       int32(cfg.ReloadPort),
 					Protocol:   corev1.ProtocolTCP,
-					TargetPort: intstr.FromInt(45550),
+					TargetPort: intstr.FromInt(cfg.ReloadPort),
+				},
+				corev1.ServicePort{
+					Name:       MetricsPortName,
+					Port:       int32(cfg.FluentdMetricsPort),
+					Protocol:   corev1.ProtocolTCP,
+					TargetPort: intstr.FromInt(cfg.FluentdMetricsPort),
 				},
 			},
 		},
@@ -125,7 +186,7 @@ func NewFluentdSvcSyncer(c client.Client, scheme *runtime.Scheme) syncer.Interfa
 
 	sync := &fdSvcSyncer{obj}
 
-	return syncer.NewObjectSyncer("Service", nil, obj, c, scheme, sync.SyncFn)
+	return syncer.NewObjectSyncer("Service", owner, obj, c, scheme, sync.SyncFn)
 }
 
 // SyncFn sync the Fluentd service per spec
@@ -157,32 +218,67 @@ func (s *fdCfgMapSyncer) SyncFn() error {
 		out.ObjectMeta.Labels[k] = v
 	}
 
-	if len(s.data) > 0 {
-		out.BinaryData = map[string][]byte{
-			"fluent.conf": s.data,
-		}
-	} else if len(out.BinaryData) == 0 {
-		if d, err := utils.GetCfgMapData("fluentd"); err != nil {
+	conf := s.data[resources.ConfigFile]
+	if len(conf) == 0 {
+		var err error
+		if conf, err = getDefaultConfig(s.cfg); err != nil {
 			return err
-		} else {
-			out.BinaryData = d
 		}
 	}
 
+	return utils.SyncCfgMapData(s.recorder, out, map[string][]byte{resources.ConfigFile: conf})
+}
+
+// SyncFn syncs the secret of files referenced by outputs
+func (s *fdFilesSyncer) SyncFn() error {
+	out := s.input.(*corev1.Secret)
+	if len(out.ObjectMeta.Labels) == 0 {
+		out.ObjectMeta.Labels = map[string]string{}
+	}
+
+	for k, v := range Labels {
+		out.ObjectMeta.Labels[k] = v
+	}
+
+	out.Data = s.data
 	return nil
 }
 
+// getDefaultConfig renders configuration used until outputs are defined, which drops all records
+func getDefaultConfig(cfg *options.Config) ([]byte, error) {
+	renderers := []resources.Resource{
+		resources.NewSystem(cfg),
+		resources.NewSource(cfg),
+		resources.NewMonitor(cfg),
+		resources.NewRouter(nil),
+	}
+
+	var buff [][]byte
+	for _, r := range renderers {
+		out, err := r.Render()
+		if err != nil {
+			return nil, err
+		}
+		buff = append(buff, out)
+	}
+
+	return bytes.Join(buff, []byte("\n\n")), nil
+}
+
 // SyncFn syncs the Fluentd cluster object with operator spec
 func (s *fdSyncer) SyncFn() error {
 	annotations := map[string]string{
 		"prometheus.io/scrape": "true",
-		"prometheus.io/port":   "2020",
-		"prometheus.io/path":   "/api/v1/metrics/prometheus",
+		"prometheus.io/port":   strconv.Itoa(s.cfg.FluentdMetricsPort),
+		"prometheus.io/path":   resources.MetricsPath,
 	}
 
 	out := s.input.(*appsv1.Deployment)
 
 	var replicas int32 = 1
+	if s.spec.Replicas != nil {
+		replicas = *s.spec.Replicas
+	}
 	out.ObjectMeta.Labels = Labels
 	out.Spec.Replicas = &replicas // TODO: Use HPA
 	out.Spec.Selector = metav1.SetAsLabelSelector(getLabels())
@@ -195,6 +291,10 @@ func (s *fdSyncer) SyncFn() error {
 		out.Spec.Template.ObjectMeta.Annotations[k] = v
 	}
 
+	if len(s.tlsChecksum) > 0 {
+		out.Spec.Template.ObjectMeta.Annotations[certs.ChecksumAnnotation] = s.tlsChecksum
+	}
+
 	if len(out.Spec.Template.ObjectMeta.Labels) == 0 {
 		out.Spec.Template.ObjectMeta.Labels = map[string]string{}
 	}
@@ -203,10 +303,20 @@ func (s *fdSyncer) SyncFn() error {
 		out.Spec.Template.ObjectMeta.Labels[k] = v
 	}
 
-	return mergo.Merge(&out.Spec.Template.Spec, getPodSpec(), mergo.WithTransformers(transformers.PodSpec))
+	podSpec := getPodSpec(s.cfg)
+	utils.ApplyComponentSpec(&podSpec, s.spec)
+
+	if err := mergo.Merge(&out.Spec.Template.Spec, podSpec, mergo.WithTransformers(transformers.PodSpec)); err != nil {
+		return err
+	}
+
+	// Merge keeps existing values, these can be unset in LoggingConfig
+	out.Spec.Template.Spec.NodeSelector = podSpec.NodeSelector
+	out.Spec.Template.Spec.PriorityClassName = podSpec.PriorityClassName
+	return nil
 }
 
-func getPodSpec() corev1.PodSpec {
+func getPodSpec(cfg *options.Config) corev1.PodSpec {
 	return corev1.PodSpec{
 		Tolerations: []corev1.Toleration{
 			{
@@ -217,20 +327,16 @@ func getPodSpec() corev1.PodSpec {
 		Containers: []corev1.Container{
 			{
 				Name:            "fluentd",
-				Image:           *(options.FluentdImage),
+				Image:           cfg.FluentdImage,
 				ImagePullPolicy: "IfNotPresent",
 				Ports: []corev1.ContainerPort{{
-					Name:          "prometheus", // TODO: customize
-					ContainerPort: 2020,
+					Name:          MetricsPortName,
+					ContainerPort: int32(cfg.FluentdMetricsPort),
 				}, {
 					Name:          "source",
-					ContainerPort: 62073,
-				}},
-				Env: []corev1.EnvVar{{
-					Name:  "FLUENT_ELASTICSEARCH_SED_DISABLE",
-					Value: "1",
+					ContainerPort: int32(cfg.ForwardPort),
 				}},
-				// TODO: Customize
+				Env: getEnv(cfg),
 				Resources: corev1.ResourceRequirements{
 					Requests: corev1.ResourceList{
 						"cpu":    resource.MustParse("100m"),
@@ -241,35 +347,93 @@ func getPodSpec() corev1.PodSpec {
 						"memory": resource.MustParse("200Mi"),
 					},
 				},
-				VolumeMounts: getVolumeMounts(),
+				VolumeMounts: getVolumeMounts(cfg),
 			},
 		},
-		Volumes:            getVolumes(),
-		ServiceAccountName: *(options.SvcAcct),
+		Volumes:            getVolumes(cfg),
+		ServiceAccountName: cfg.SvcAcct,
+	}
+}
+
+func getEnv(cfg *options.Config) []corev1.EnvVar {
+	env := []corev1.EnvVar{{
+		Name:  "FLUENT_ELASTICSEARCH_SED_DISABLE",
+		Value: "1",
+	}}
+
+	if cfg.SecureForward {
+		env = append(env, corev1.EnvVar{
+			Name: certs.SharedKeyEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: certs.ServerSecret},
+					Key:                  certs.SharedKeyKey,
+				},
+			},
+		})
 	}
+
+	return env
 }
 
-func getVolumeMounts() []corev1.VolumeMount {
-	return []corev1.VolumeMount{
+func getVolumeMounts(cfg *options.Config) []corev1.VolumeMount {
+	mounts := []corev1.VolumeMount{
 		{
 			Name:      cfgMapName,
 			MountPath: volumePaths[cfgMapName],
 			ReadOnly:  true,
 		},
 	}
+
+	if cfg.SecureForward {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      certs.ServerSecret,
+			MountPath: volumePaths[certs.ServerSecret],
+			ReadOnly:  true,
+		})
+	}
+
+	return mounts
 }
 
-func getVolumes() []corev1.Volume {
-	return []corev1.Volume{
+func getVolumes(cfg *options.Config) []corev1.Volume {
+	// Files referenced by outputs are kept in a secret, as they may be credentials, next to the configuration
+	volumes := []corev1.Volume{
 		{
 			Name: cfgMapName,
 			VolumeSource: corev1.VolumeSource{
-				ConfigMap: &corev1.ConfigMapVolumeSource{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: cfgMapName,
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{
+						{
+							ConfigMap: &corev1.ConfigMapProjection{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: cfgMapName,
+								},
+							},
+						},
+						{
+							Secret: &corev1.SecretProjection{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: filesName,
+								},
+							},
+						},
 					},
 				},
 			},
 		},
 	}
+
+	if cfg.SecureForward {
+		volumes = append(volumes, corev1.Volume{
+			Name: certs.ServerSecret,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: certs.ServerSecret,
+				},
+			},
+		})
+	}
+
+	return volumes
 }
@@ -15,37 +15,186 @@ package syncer_test
 
 import (
 	"context"
-	"path/filepath"
-	"runtime"
 	"testing"
 
+	"github.com/platform9/fluentd-operator/pkg/apis"
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/certs"
 	"github.com/platform9/fluentd-operator/pkg/fluentd/internal/syncer"
 	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	api_rt "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
-func TestMain(t *testing.T) {
-	_, fn, _, ok := runtime.Caller(0)
-	assert.True(t, ok)
-	*(options.CfgDir) = filepath.Join(filepath.Dir(fn), "../../../../etc/conf")
+func newScheme(t *testing.T) *api_rt.Scheme {
+	s := api_rt.NewScheme()
+	assert.Nil(t, apis.AddToScheme(s))
+	return s
+}
+
+func newOwner(spec v1alpha1.ComponentSpec) *v1alpha1.LoggingConfig {
+	return &v1alpha1.LoggingConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: v1alpha1.LoggingConfigName, UID: "uid"},
+		Spec:       v1alpha1.LoggingConfigSpec{Fluentd: spec},
+	}
 }
 
 func TestSvcSyncer(t *testing.T) {
-	s := syncer.NewFluentdSvcSyncer(fake.NewFakeClient(), &api_rt.Scheme{})
+	s := syncer.NewFluentdSvcSyncer(fake.NewFakeClient(), newScheme(t), options.New(), newOwner(v1alpha1.ComponentSpec{}))
 	_, err := s.Sync(context.TODO())
 	assert.Nil(t, err)
 }
 
 func TestCfgMapSyncer(t *testing.T) {
-	c := syncer.NewFluentdCfgMapSyncer(fake.NewFakeClient(), &api_rt.Scheme{})
+	c := syncer.NewFluentdCfgMapSyncer(fake.NewFakeClient(), newScheme(t), record.NewFakeRecorder(8), options.New(), newOwner(v1alpha1.ComponentSpec{}))
 	_, err := c.Sync(context.TODO())
 	assert.Nil(t, err)
+	assert.Contains(t, string(c.Object().(*corev1.ConfigMap).BinaryData["fluent.conf"]), "@type prometheus")
+
+	conf := string(c.Object().(*corev1.ConfigMap).BinaryData["fluent.conf"])
+	assert.Contains(t, conf, "rpc_endpoint 0.0.0.0:45550")
+	assert.Contains(t, conf, "port 62073")
+	assert.Contains(t, conf, "<match **>\n    @type null")
+}
+
+func TestCfgMapDrift(t *testing.T) {
+	c := fake.NewFakeClient()
+	e := record.NewFakeRecorder(8)
+	owner := newOwner(v1alpha1.ComponentSpec{})
+	_, err := syncer.NewFluentdCfgMapSyncer(c, newScheme(t), e, options.New(), owner, map[string][]byte{"fluent.conf": []byte("desired")}).Sync(context.TODO())
+	assert.Nil(t, err)
+
+	cm := &corev1.ConfigMap{}
+	key := types.NamespacedName{Namespace: options.New().LogNs, Name: "fluentd-config"}
+	assert.Nil(t, c.Get(context.TODO(), key, cm))
+	cm.BinaryData["fluent.conf"] = []byte("edited")
+	assert.Nil(t, c.Update(context.TODO(), cm))
+
+	_, err = syncer.NewFluentdCfgMapSyncer(c, newScheme(t), e, options.New(), owner, map[string][]byte{"fluent.conf": []byte("desired")}).Sync(context.TODO())
+	assert.Nil(t, err)
+	assert.Contains(t, <-e.Events, utils.DriftReason)
+	assert.Nil(t, c.Get(context.TODO(), key, cm))
+	assert.Equal(t, "desired", string(cm.BinaryData["fluent.conf"]))
+}
+
+func TestFilesSyncer(t *testing.T) {
+	c := fake.NewFakeClient()
+	data := map[string][]byte{"fluent.conf": []byte("conf"), "kafka.ssl_client_cert_key": []byte("key")}
+	_, err := syncer.NewFluentdFilesSyncer(c, newScheme(t), options.New(), newOwner(v1alpha1.ComponentSpec{}), data).Sync(context.TODO())
+	assert.Nil(t, err)
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: options.New().LogNs, Name: "fluentd-files"}
+	assert.Nil(t, c.Get(context.TODO(), key, secret))
+	assert.Equal(t, map[string][]byte{"kafka.ssl_client_cert_key": []byte("key")}, secret.Data)
+
+	// Credentials aren't written to the config map
+	cm := syncer.NewFluentdCfgMapSyncer(c, newScheme(t), record.NewFakeRecorder(8), options.New(), newOwner(v1alpha1.ComponentSpec{}), data)
+	_, err = cm.Sync(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"fluent.conf": []byte("conf")}, cm.Object().(*corev1.ConfigMap).BinaryData)
 }
 
 func TestFluentdSyncer(t *testing.T) {
-	f := syncer.NewFluentdSyncer(fake.NewFakeClient(), &api_rt.Scheme{})
+	f := syncer.NewFluentdSyncer(fake.NewFakeClient(), newScheme(t), options.New(), newOwner(v1alpha1.ComponentSpec{}), "")
 	_, err := f.Sync(context.TODO())
 	assert.Nil(t, err)
+
+	d := f.Object().(*appsv1.Deployment)
+	assert.Equal(t, "24231", d.Spec.Template.Annotations["prometheus.io/port"])
+	assert.Equal(t, "/metrics", d.Spec.Template.Annotations["prometheus.io/path"])
+	assert.Equal(t, int32(24231), d.Spec.Template.Spec.Containers[0].Ports[0].ContainerPort)
+
+	// Configuration and files of outputs are mounted in one directory
+	sources := d.Spec.Template.Spec.Volumes[0].Projected.Sources
+	assert.Equal(t, "fluentd-config", sources[0].ConfigMap.Name)
+	assert.Equal(t, "fluentd-files", sources[1].Secret.Name)
+
+	// Deployment is garbage collected along with LoggingConfig
+	ref := metav1.GetControllerOf(d)
+	assert.NotNil(t, ref)
+	assert.Equal(t, "LoggingConfig", ref.Kind)
+	assert.Equal(t, v1alpha1.LoggingConfigName, ref.Name)
+}
+
+func TestLoggingConfig(t *testing.T) {
+	var replicas int32 = 3
+	spec := v1alpha1.ComponentSpec{
+		Image: "fluentd:custom",
+		Resources: &corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{"memory": resource.MustParse("1Gi")},
+		},
+		Tolerations:       []corev1.Toleration{{Key: "dedicated", Value: "logging", Effect: "NoSchedule"}},
+		NodeSelector:      map[string]string{"role": "logging"},
+		PriorityClassName: "system-cluster-critical",
+		Replicas:          &replicas,
+		Env:               []corev1.EnvVar{{Name: "RUBY_GC_HEAP_OLDOBJECT_LIMIT_FACTOR", Value: "0.9"}},
+	}
+
+	c := fake.NewFakeClient()
+	cfg := options.New()
+	f := syncer.NewFluentdSyncer(c, newScheme(t), cfg, newOwner(spec), "")
+	_, err := f.Sync(context.TODO())
+	assert.Nil(t, err)
+
+	d := f.Object().(*appsv1.Deployment)
+	pod := d.Spec.Template.Spec
+	assert.Equal(t, replicas, *d.Spec.Replicas)
+	assert.Equal(t, "fluentd:custom", pod.Containers[0].Image)
+	assert.Equal(t, "1Gi", pod.Containers[0].Resources.Limits.Memory().String())
+	assert.Equal(t, spec.Tolerations, pod.Tolerations)
+	assert.Equal(t, spec.NodeSelector, pod.NodeSelector)
+	assert.Equal(t, spec.PriorityClassName, pod.PriorityClassName)
+	assert.Equal(t, spec.Env[0], pod.Containers[0].Env[len(pod.Containers[0].Env)-1])
+
+	// Removing customizations restores defaults
+	f = syncer.NewFluentdSyncer(c, newScheme(t), cfg, newOwner(v1alpha1.ComponentSpec{}), "")
+	_, err = f.Sync(context.TODO())
+	assert.Nil(t, err)
+
+	d = f.Object().(*appsv1.Deployment)
+	pod = d.Spec.Template.Spec
+	assert.Equal(t, int32(1), *d.Spec.Replicas)
+	assert.Equal(t, cfg.FluentdImage, pod.Containers[0].Image)
+	assert.Equal(t, "node-role.kubernetes.io/master", pod.Tolerations[0].Key)
+	assert.Empty(t, pod.NodeSelector)
+	assert.Empty(t, pod.PriorityClassName)
+}
+
+func TestMonitorSyncer(t *testing.T) {
+	m := syncer.NewFluentdMonitorSyncer(fake.NewFakeClient(), newScheme(t), options.New(), newOwner(v1alpha1.ComponentSpec{}))
+	_, err := m.Sync(context.TODO())
+	assert.Nil(t, err)
+
+	endpoints, _, _ := unstructured.NestedSlice(m.Object().(*unstructured.Unstructured).Object, "spec", "endpoints")
+	assert.Equal(t, syncer.MetricsPortName, endpoints[0].(map[string]interface{})["port"])
+}
+
+func TestSecureFluentdSyncer(t *testing.T) {
+	cfg := options.New()
+	cfg.SecureForward = true
+
+	f := syncer.NewFluentdSyncer(fake.NewFakeClient(), newScheme(t), cfg, newOwner(v1alpha1.ComponentSpec{}), "abc")
+	_, err := f.Sync(context.TODO())
+	assert.Nil(t, err)
+
+	d := f.Object().(*appsv1.Deployment)
+	assert.Equal(t, "abc", d.Spec.Template.Annotations[certs.ChecksumAnnotation])
+
+	spec := d.Spec.Template.Spec
+	assert.Equal(t, certs.ServerSecret, spec.Volumes[len(spec.Volumes)-1].Secret.SecretName)
+	assert.Equal(t, certs.FluentdMountPath, spec.Containers[0].VolumeMounts[len(spec.Volumes)-1].MountPath)
+
+	env := spec.Containers[0].Env[len(spec.Containers[0].Env)-1]
+	assert.Equal(t, certs.SharedKeyEnv, env.Name)
+	assert.Equal(t, certs.SharedKeyKey, env.ValueFrom.SecretKeyRef.Key)
 }
@@ -15,21 +15,25 @@ package fluentd
 
 import (
 	"context"
-	"fmt"
-	"io/ioutil"
-	"net/http"
+	"time"
 
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/go-logr/logr"
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/cache"
 	fdsyncer "github.com/platform9/fluentd-operator/pkg/fluentd/internal/syncer"
+	"github.com/platform9/fluentd-operator/pkg/metrics"
 	"github.com/platform9/fluentd-operator/pkg/options"
 	"github.com/platform9/fluentd-operator/pkg/utils"
 	"github.com/presslabs/controller-util/syncer"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -39,35 +43,58 @@ var log = logf.Log.WithName("fluentd_reconciler")
 
 // Reconciler reconciles fluentd deployment
 type Reconciler struct {
-	// This client, initialized using mgr.Client() above, is a split client
-	// that reads objects from the cache and writes to the apiserver
-	client   client.Client
+	// This client is a split client that reads objects from the cache of logging namespace and writes to
+	// the apiserver
+	client client.Client
+	// Outputs are rendered from apiserver, since secrets they reference may be in any namespace and caching
+	// them would watch secrets of the whole cluster
+	reader   client.Reader
 	scheme   *runtime.Scheme
 	recorder record.EventRecorder
+	mapper   meta.RESTMapper
+	cfg      *options.Config
 }
 
 // New returns new instance of reconciler
-func New(mgr manager.Manager) *Reconciler {
+func New(mgr manager.Manager, cfg *options.Config) (*Reconciler, error) {
+	c, err := cache.Get(mgr, cfg.LogNs)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Reconciler{
-		client:   mgr.GetClient(),
+		client:   c.Client,
+		reader:   mgr.GetAPIReader(),
 		scheme:   mgr.GetScheme(),
 		recorder: mgr.GetEventRecorderFor("controller.fluentd"),
-	}
+		mapper:   mgr.GetRESTMapper(),
+		cfg:      cfg,
+	}, nil
+}
+
+// Request returns the reconcile request of LoggingConfig, which owns fluentd deployment and all objects managed
+// for it. Reconciling it syncs them all.
+func (r *Reconciler) Request() reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Name: v1alpha1.LoggingConfigName}}
+}
+
+// deploymentKey returns name of fluentd deployment
+func deploymentKey(cfg *options.Config) types.NamespacedName {
+	return types.NamespacedName{Namespace: cfg.LogNs, Name: fdsyncer.Name}
 }
 
 // Reconcile reads state of cluster for Deployment objects and makes
 // changes per how the controller definition needs to be
 func (r *Reconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
-	// TODO: Watch deployments only in current namespace
 	instance := &appsv1.Deployment{}
 
-	// Only interested in configured namespace for fluentd deployment
-	if request.Namespace != *(options.LogNs) {
+	// Objects managed for fluentd are enqueued by their owner
+	if request != r.Request() {
 		return reconcile.Result{}, nil
 	}
 
-	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	err := r.client.Get(context.TODO(), deploymentKey(r.cfg), instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return reconcile.Result{}, r.CreateIfNeeded()
@@ -88,9 +115,25 @@ func (r *Reconciler) reconcile(reqLogger logr.Logger, current *appsv1.Deployment
 		return reconcile.Result{}, nil
 	}
 
-	syncers := []syncer.Interface{fdsyncer.NewFluentdSyncer(r.client, r.scheme),
-		fdsyncer.NewFluentdCfgMapSyncer(r.client, r.scheme),
-		fdsyncer.NewFluentdSvcSyncer(r.client, r.scheme),
+	lc, err := utils.EnsureLoggingConfig(r.client)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	fwdTLS, err := syncTLS(r.client, r.scheme, r.recorder, r.cfg, lc)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	data, renderErr := render(r.reader, r.cfg)
+	if data == nil {
+		return reconcile.Result{}, renderErr
+	}
+
+	syncers := []syncer.Interface{fdsyncer.NewFluentdSyncer(r.client, r.scheme, r.cfg, lc, fwdTLS.checksum),
+		fdsyncer.NewFluentdFilesSyncer(r.client, r.scheme, r.cfg, lc, data),
+		fdsyncer.NewFluentdCfgMapSyncer(r.client, r.scheme, r.recorder, r.cfg, lc, data),
+		fdsyncer.NewFluentdSvcSyncer(r.client, r.scheme, r.cfg, lc),
 	}
 
 	for _, sync := range syncers {
@@ -99,18 +142,46 @@ func (r *Reconciler) reconcile(reqLogger logr.Logger, current *appsv1.Deployment
 		}
 	}
 
-	return reconcile.Result{}, nil
+	if err := r.syncMonitor(lc); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// Outputs which failed to render are retried
+	return reconcile.Result{RequeueAfter: fwdTLS.requeueAfter}, renderErr
 }
 
 // CreateIfNeeded creates fluentd deployment if needed
 func (r *Reconciler) CreateIfNeeded() error {
-	return createIfNeeded(r.client, r.scheme, r.recorder)
+	lc, err := utils.EnsureLoggingConfig(r.client)
+	if err != nil {
+		return err
+	}
+
+	data, renderErr := render(r.reader, r.cfg)
+	if data == nil {
+		return renderErr
+	}
+
+	if err := createIfNeeded(r.client, r.scheme, r.recorder, r.cfg, lc, data); err != nil {
+		return err
+	}
+	if err := r.syncMonitor(lc); err != nil {
+		return err
+	}
+	return renderErr
 }
 
-func createIfNeeded(c client.Client, s *runtime.Scheme, e record.EventRecorder) error {
-	syncers := []syncer.Interface{fdsyncer.NewFluentdSyncer(c, s),
-		fdsyncer.NewFluentdCfgMapSyncer(c, s),
-		fdsyncer.NewFluentdSvcSyncer(c, s),
+func createIfNeeded(c client.Client, s *runtime.Scheme, e record.EventRecorder, cfg *options.Config,
+	lc *v1alpha1.LoggingConfig, data map[string][]byte) error {
+	fwdTLS, err := syncTLS(c, s, e, cfg, lc)
+	if err != nil {
+		return err
+	}
+
+	syncers := []syncer.Interface{fdsyncer.NewFluentdSyncer(c, s, cfg, lc, fwdTLS.checksum),
+		fdsyncer.NewFluentdFilesSyncer(c, s, cfg, lc, data),
+		fdsyncer.NewFluentdCfgMapSyncer(c, s, e, cfg, lc, data),
+		fdsyncer.NewFluentdSvcSyncer(c, s, cfg, lc),
 	}
 
 	for _, sync := range syncers {
@@ -125,41 +196,94 @@ func createIfNeeded(c client.Client, s *runtime.Scheme, e record.EventRecorder)
 	return nil
 }
 
-// Refresh changes the fluentd configmap and reload it
-func (r *Reconciler) Refresh(data []byte) error {
-	return refresh(r.client, r.scheme, r.recorder, data)
-}
+// Refresh renders configuration of all outputs into the fluentd configmap and reloads it. Configuration is
+// applied even if some outputs fail to render, which is reported by a *RenderError. ErrNotLoaded is returned
+// until fluentd runs the configuration, Refresh is then retried.
+func (r *Reconciler) Refresh() error {
+	data, renderErr := render(r.reader, r.cfg)
+	if data == nil {
+		return renderErr
+	}
 
-func refresh(c client.Client, s *runtime.Scheme, e record.EventRecorder, data []byte) error {
-	syncers := []syncer.Interface{
-		fdsyncer.NewFluentdCfgMapSyncer(c, s, data),
+	if err := refresh(r.client, r.scheme, r.recorder, r.cfg, data); err != nil {
+		return err
 	}
+	return renderErr
+}
 
-	for _, sync := range syncers {
-		if err := syncer.Sync(context.TODO(), sync, e); err != nil {
-			return err
-		}
+// Flush makes fluentd write out buffers of all outputs
+func (r *Reconciler) Flush() error {
+	return flush(r.cfg)
+}
+
+// render returns configuration to apply, or nil along with the error if there is none. Configuration lacks
+// outputs which failed to render if error is a *RenderError.
+func render(cl client.Reader, cfg *options.Config) (map[string][]byte, error) {
+	data, err := Render(cl, cfg)
+	if _, partial := err.(*RenderError); err != nil && !partial {
+		return nil, err
 	}
+	return data, err
+}
 
-	// Reload service, if needed
-	svcURL := fmt.Sprintf("http://%s:%d/api/config.reload", *(options.ReloadHost), *(options.ReloadPort))
-	req, err := http.NewRequest("POST", svcURL, nil)
+func refresh(c client.Client, s *runtime.Scheme, e record.EventRecorder, cfg *options.Config, data map[string][]byte) error {
+	lc, err := utils.EnsureLoggingConfig(c)
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	// Files are written before configuration referencing them
+	if err := syncer.Sync(context.TODO(), fdsyncer.NewFluentdFilesSyncer(c, s, cfg, lc, data), e); err != nil {
+		return err
+	}
+
+	cmSyncer := fdsyncer.NewFluentdCfgMapSyncer(c, s, e, cfg, lc, data)
+	if err := syncer.Sync(context.TODO(), cmSyncer, e); err != nil {
+		return err
+	}
+
+	// Configuration of a config map kept as edited isn't known, nor is the default configuration marked
+	label := configLabel(data)
+	if cm, ok := cmSyncer.Object().(*corev1.ConfigMap); ok && cm.Annotations[utils.OverrideAnnotation] == "true" {
+		label = ""
+	}
+
+	// Configuration already running isn't reloaded
+	if len(label) > 0 {
+		if ok, err := loaded(cfg, label); err == nil && ok {
+			return nil
+		}
+	}
+
+	start := time.Now()
+	err = reload(cfg)
+	metrics.ReloadDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
 		log.Info("When reloading fluentd", "error", err)
-	} else {
-		respStr, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			log.Info("fluentd reload response", "status", resp.StatusCode, "message", string(respStr))
-		} else {
-			log.Error(err, "when reading response")
+		metrics.Reloads.WithLabelValues(metrics.ResultFailure).Inc()
+		return err
+	}
+	metrics.Reloads.WithLabelValues(metrics.ResultSuccess).Inc()
+
+	if len(label) > 0 {
+		ok, err := loaded(cfg, label)
+		if err != nil {
+			return err
 		}
-		resp.Body.Close()
+		if !ok {
+			return ErrNotLoaded
+		}
+	}
+	metrics.LastApply.SetToCurrentTime()
+	return nil
+}
+
+// syncMonitor creates ServiceMonitor of prometheus-operator, if it is installed
+func (r *Reconciler) syncMonitor(lc *v1alpha1.LoggingConfig) error {
+	ok, err := metrics.HasKind(r.mapper, metrics.ServiceMonitorGVK)
+	if !ok {
+		return err
 	}
 
-	return err
+	return syncer.Sync(context.TODO(), fdsyncer.NewFluentdMonitorSyncer(r.client, r.scheme, r.cfg, lc), r.recorder)
 }
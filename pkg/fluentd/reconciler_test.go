@@ -14,21 +14,33 @@ limitations under the License.
 package fluentd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"path/filepath"
-	"runtime"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/platform9/fluentd-operator/pkg/apis"
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/metrics"
 	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/pkg/resources"
+	"github.com/platform9/fluentd-operator/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	api_rt "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 type FakeManager struct {
@@ -46,15 +58,50 @@ func (fm *FakeManager) GetRecorder(name string) record.EventRecorder {
 	return record.NewFakeRecorder(128)
 }
 func TestMain(t *testing.T) {
-	_, fn, _, ok := runtime.Caller(0)
-	assert.True(t, ok)
-	*(options.CfgDir) = filepath.Join(filepath.Dir(fn), "../../etc/conf")
+	// Fake client serves LoggingConfig
+	assert.Nil(t, apis.AddToScheme(scheme.Scheme))
 }
 
 func TestCreate(t *testing.T) {
-	err := createIfNeeded(fake.NewFakeClient(), &api_rt.Scheme{}, record.NewFakeRecorder(128))
+	c := fake.NewFakeClient()
+	lc, err := utils.EnsureLoggingConfig(c)
+	assert.Nil(t, err)
 
+	cfg := options.New()
+	err = createIfNeeded(c, scheme.Scheme, record.NewFakeRecorder(128), cfg, lc, nil)
 	assert.Nil(t, err)
+
+	d := &appsv1.Deployment{}
+	assert.Nil(t, c.Get(context.TODO(), deploymentKey(cfg), d))
+	assert.Equal(t, v1alpha1.LoggingConfigName, metav1.GetControllerOf(d).Name)
+}
+
+func TestCreateWithLoggingConfig(t *testing.T) {
+	lc := &v1alpha1.LoggingConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: v1alpha1.LoggingConfigName},
+		Spec: v1alpha1.LoggingConfigSpec{
+			Fluentd: v1alpha1.ComponentSpec{Image: "fluentd:custom"},
+		},
+	}
+	c := fake.NewFakeClient(lc)
+	cfg := options.New()
+	cfg.LogNs = "logging-custom"
+	assert.Nil(t, createIfNeeded(c, scheme.Scheme, record.NewFakeRecorder(128), cfg, lc, nil))
+
+	d := &appsv1.Deployment{}
+	assert.Nil(t, c.Get(context.TODO(), deploymentKey(cfg), d))
+	assert.Equal(t, "fluentd:custom", d.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestReconcileOwnerRequest(t *testing.T) {
+	// Objects managed for fluentd are enqueued by their owner LoggingConfig, other requests are ignored
+	r := &Reconciler{cfg: options.New()}
+	assert.Equal(t, v1alpha1.LoggingConfigName, r.Request().Name)
+	assert.Empty(t, r.Request().Namespace)
+
+	result, err := r.Reconcile(reconcile.Request{NamespacedName: deploymentKey(r.cfg)})
+	assert.Nil(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
 }
 
 func TestReconcile(t *testing.T) {
@@ -69,13 +116,129 @@ func TestReconcile(t *testing.T) {
 	u, err := url.Parse(ts.URL)
 	assert.Nil(t, err)
 
-	*(options.ReloadHost) = u.Hostname()
-	*(options.ReloadPort), err = strconv.Atoi(u.Port())
+	cfg := options.New()
+	cfg.ReloadHost = u.Hostname()
+	cfg.ReloadPort, err = strconv.Atoi(u.Port())
+
+	assert.Nil(t, err)
+
+	success := testutil.ToFloat64(metrics.Reloads.WithLabelValues(metrics.ResultSuccess))
+
+	var data map[string][]byte
+	err = refresh(fake.NewFakeClient(), scheme.Scheme, record.NewFakeRecorder(128), cfg, data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, success+1, testutil.ToFloat64(metrics.Reloads.WithLabelValues(metrics.ResultSuccess)))
+	assert.NotZero(t, testutil.ToFloat64(metrics.LastApply))
+}
+
+// fakeFluentd serves RPC of fluentd, which loads mounted configuration when reloaded
+type fakeFluentd struct {
+	mounted string
+	conf    string
+	reloads int
+	status  int
+}
 
+func (f *fakeFluentd) serve(t *testing.T) (*httptest.Server, *options.Config) {
+	r := mux.NewRouter()
+	r.HandleFunc("/api/config.reload", func(w http.ResponseWriter, r *http.Request) {
+		f.reloads++
+		w.WriteHeader(f.status)
+		if f.status == http.StatusOK {
+			f.conf = f.mounted
+		}
+	}).Methods("POST")
+	r.HandleFunc("/api/config.getDump", func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, json.NewEncoder(w).Encode(map[string]string{"conf": f.conf}))
+	}).Methods("POST")
+
+	ts := httptest.NewServer(r)
+	u, err := url.Parse(ts.URL)
 	assert.Nil(t, err)
 
-	var data []byte
-	err = refresh(fake.NewFakeClient(), &api_rt.Scheme{}, record.NewFakeRecorder(128), data)
+	cfg := options.New()
+	cfg.ReloadHost = u.Hostname()
+	cfg.ReloadPort, err = strconv.Atoi(u.Port())
+	assert.Nil(t, err)
+	return ts, cfg
+}
+
+func TestRefreshLoaded(t *testing.T) {
+	f := &fakeFluentd{status: http.StatusOK}
+	ts, cfg := f.serve(t)
+	defer ts.Close()
+
+	data, err := Render(fake.NewFakeClient(), cfg)
+	assert.Nil(t, err)
+	label := configLabel(data)
+	assert.Contains(t, label, configLabelPrefix)
+
+	c := fake.NewFakeClient()
+	e := record.NewFakeRecorder(128)
+
+	// Config map mounted by fluentd isn't updated yet
+	assert.Equal(t, ErrNotLoaded, refresh(c, scheme.Scheme, e, cfg, data))
+	assert.Equal(t, 1, f.reloads)
+
+	f.mounted = string(data[resources.ConfigFile])
+	assert.Nil(t, refresh(c, scheme.Scheme, e, cfg, data))
+	assert.Equal(t, 2, f.reloads)
+	assert.Contains(t, f.conf, fmt.Sprintf("<label %s>", label))
+
+	// Running configuration isn't reloaded
+	assert.Nil(t, refresh(c, scheme.Scheme, e, cfg, data))
+	assert.Equal(t, 2, f.reloads)
+
+	// Failed reloads are errors
+	f.conf = ""
+	f.status = http.StatusInternalServerError
+	err = refresh(c, scheme.Scheme, e, cfg, data)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrNotLoaded, err)
+}
+
+func TestFlush(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/api/plugins.flushBuffers", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("POST")
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	assert.Nil(t, err)
+
+	cfg := options.New()
+	cfg.ReloadHost = u.Hostname()
+	cfg.ReloadPort, err = strconv.Atoi(u.Port())
+	assert.Nil(t, err)
+	assert.Nil(t, flush(cfg))
+
+	ts.Close()
+	assert.NotNil(t, flush(cfg))
+}
+
+func TestRPCTimeout(t *testing.T) {
+	defer func(c *http.Client) { rpcClient = c }(rpcClient)
+	rpcClient = &http.Client{Timeout: 100 * time.Millisecond}
+
+	// Unresponsive fluentd doesn't hold back the reconciler
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	u, err := url.Parse(ts.URL)
+	assert.Nil(t, err)
 
+	cfg := options.New()
+	cfg.ReloadHost = u.Hostname()
+	cfg.ReloadPort, err = strconv.Atoi(u.Port())
 	assert.Nil(t, err)
+	assert.NotNil(t, flush(cfg))
+	assert.NotNil(t, reload(cfg))
 }
@@ -0,0 +1,179 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluentd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	loggingv1alpha1 "github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/metrics"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/pkg/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DrainedAnnotation marks a deleted Output whose buffer was flushed, so that it is left out of configuration
+const DrainedAnnotation = "logging.pf9.io/drained"
+
+// configLabelPrefix prefixes the label marking a configuration by checksum of its files. The label routes no
+// records, it tells whether fluentd loaded the configuration.
+const configLabelPrefix = "@config-"
+
+var configLabelRegexp = regexp.MustCompile(`<label (` + configLabelPrefix + `[0-9a-f]+)>`)
+
+// RenderError lists Outputs left out of configuration because they failed to render, by name
+type RenderError struct {
+	Outputs map[string]error
+}
+
+func (e *RenderError) Error() string {
+	names := []string{}
+	for name := range e.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := []string{}
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", name, e.Outputs[name]))
+	}
+	return fmt.Sprintf("failed to render outputs: %s", strings.Join(msgs, "; "))
+}
+
+// Render returns fluentd configuration routing logs to all Outputs. Outputs and secrets they reference are read
+// with cl. Logs are no longer routed to deleted Outputs, which are kept in configuration until drained, if
+// buffers are flushed on delete. Outputs failing to render are left out, so that they don't hold back changes
+// of others, and are reported by a *RenderError returned along with the configuration. Configuration is
+// returned as files of fluentd configuration directory, by name, along with files referenced by Outputs.
+func Render(cl client.Reader, cfg *options.Config) (map[string][]byte, error) {
+	// Simple algorithm to render all outputs once one changes. This lets us keep thing simple and write entire config
+	// as one.
+	instances := &loggingv1alpha1.OutputList{}
+	lo := client.ListOptions{}
+
+	err := cl.List(context.TODO(), instances, &lo)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string][]byte{}
+	routed := []*resources.Output{}
+	sections := [][]byte{}
+	failed := map[string]error{}
+	metrics.Outputs.Reset()
+	for i := range instances.Items {
+		instance := &instances.Items[i]
+		deleting := !instance.DeletionTimestamp.IsZero()
+		if deleting && (!cfg.FlushOnDelete || instance.Annotations[DrainedAnnotation] == "true") {
+			continue
+		}
+
+		o := resources.NewOutput(cl, instance)
+		out, err := o.Render()
+		if err != nil {
+			metrics.RenderErrors.WithLabelValues(o.Name()).Inc()
+			failed[o.Name()] = err
+			continue
+		}
+
+		sections = append(sections, out)
+		for name, file := range o.Files() {
+			data[name] = file
+		}
+		if !deleting {
+			routed = append(routed, o)
+			metrics.Outputs.WithLabelValues(strings.ToLower(instance.Spec.Type)).Inc()
+		}
+	}
+
+	// Source rendering is not configurable yet.
+	renderers := []resources.Resource{
+		resources.NewSystem(cfg),
+		resources.NewSource(cfg),
+		resources.NewMonitor(cfg),
+		resources.NewRouter(routed),
+	}
+
+	var buff []byte
+	var newline bytes.Buffer
+	fmt.Fprintf(&newline, "\n\n")
+	for _, r := range renderers {
+		out, err := r.Render()
+		if err != nil {
+			return nil, err
+		}
+		buff = append(buff, out...)
+		buff = append(buff, newline.Bytes()...)
+	}
+
+	for _, out := range sections {
+		buff = append(buff, out...)
+		buff = append(buff, newline.Bytes()...)
+	}
+
+	data[resources.ConfigFile] = buff
+	buff = append(buff, renderConfigLabel(configLabelPrefix+checksum(data))...)
+	buff = append(buff, newline.Bytes()...)
+
+	metrics.ConfigSize.Set(float64(len(buff)))
+	data[resources.ConfigFile] = buff
+	if len(failed) > 0 {
+		return data, &RenderError{Outputs: failed}
+	}
+	return data, nil
+}
+
+// renderConfigLabel returns the label marking a configuration
+func renderConfigLabel(label string) []byte {
+	var ret bytes.Buffer
+	fmt.Fprintf(&ret, "<label %s>", label)
+	fmt.Fprintf(&ret, "\n    <match **>")
+	fmt.Fprintf(&ret, "\n        @type null")
+	fmt.Fprintf(&ret, "\n    </match>")
+	fmt.Fprintf(&ret, "\n</label>")
+	return ret.Bytes()
+}
+
+// configLabel returns the label marking configuration of data, or an empty string if it isn't marked, like
+// the default configuration
+func configLabel(data map[string][]byte) string {
+	m := configLabelRegexp.FindSubmatch(data[resources.ConfigFile])
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// checksum returns checksum of files of configuration data
+func checksum(data map[string][]byte) string {
+	names := []string{}
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%x\n", name, data[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluentd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/platform9/fluentd-operator/pkg/options"
+)
+
+// rpcTimeout bounds calls of fluentd RPC endpoint, which hold back reconciling fluentd and Outputs
+const rpcTimeout = 30 * time.Second
+
+var (
+	// ErrNotLoaded is returned while fluentd runs other configuration than the one applied last
+	ErrNotLoaded = errors.New("fluentd didn't load configuration yet")
+
+	rpcClient = &http.Client{Timeout: rpcTimeout}
+)
+
+// rpc calls method of fluentd RPC endpoint
+func rpc(cfg *options.Config, method string) (*http.Response, error) {
+	svcURL := fmt.Sprintf("http://%s:%d/api/%s", cfg.ReloadHost, cfg.ReloadPort, method)
+	req, err := http.NewRequest("POST", svcURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return rpcClient.Do(req)
+}
+
+// call calls method of fluentd RPC endpoint, returning the response body, or an error unless it succeeded
+func call(cfg *options.Config, method string) ([]byte, error) {
+	resp, err := rpc(cfg, method)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calling fluentd %s: %s: %s", method, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func flush(cfg *options.Config) error {
+	_, err := call(cfg, "plugins.flushBuffers")
+	return err
+}
+
+// reload makes fluentd read its configuration directory again
+func reload(cfg *options.Config) error {
+	body, err := call(cfg, "config.reload")
+	if err != nil {
+		return err
+	}
+	log.Info("fluentd reload response", "message", string(body))
+	return nil
+}
+
+// loaded returns whether the configuration fluentd runs has the label marking a configuration. fluentd keeps
+// running its previous configuration when the new one fails to load, and reads the configuration mounted from
+// the config map, which kubelet updates some time after it changed.
+func loaded(cfg *options.Config, label string) (bool, error) {
+	body, err := call(cfg, "config.getDump")
+	if err != nil {
+		return false, err
+	}
+
+	dump := struct {
+		Conf string `json:"conf"`
+	}{}
+	if err := json.Unmarshal(body, &dump); err != nil {
+		return false, err
+	}
+	return strings.Contains(dump.Conf, fmt.Sprintf("<label %s>", label)), nil
+}
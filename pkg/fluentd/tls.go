@@ -0,0 +1,98 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluentd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/platform9/fluentd-operator/pkg/certs"
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/presslabs/controller-util/syncer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rolloutRetry is how often a CA being replaced is synced, until pods mount secrets of its current step
+const rolloutRetry = time.Minute
+
+// forwardTLS is the state of forward certificates after a sync
+type forwardTLS struct {
+	checksum string
+	// requeueAfter is when certificates need to be rotated next
+	requeueAfter time.Duration
+}
+
+// getHosts returns names under which fluentd forward input is reached
+func getHosts(cfg *options.Config) []string {
+	svc := "fluentd"
+	hosts := []string{
+		svc,
+		fmt.Sprintf("%s.%s", svc, cfg.LogNs),
+		fmt.Sprintf("%s.%s.svc", svc, cfg.LogNs),
+		fmt.Sprintf("%s.%s.svc.cluster.local", svc, cfg.LogNs),
+	}
+
+	for _, h := range []string{cfg.ForwardHost, cfg.ReloadHost} {
+		found := false
+		for _, e := range hosts {
+			found = found || e == h
+		}
+		if !found && net.ParseIP(h) == nil {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
+}
+
+// syncTLS creates and rotates forward certificates, it is a no-op unless secure forward is enabled
+func syncTLS(c client.Client, s *runtime.Scheme, e record.EventRecorder, cfg *options.Config,
+	owner *v1alpha1.LoggingConfig) (forwardTLS, error) {
+	ret := forwardTLS{}
+	if !cfg.SecureForward {
+		return ret, nil
+	}
+
+	rolledOut, err := certs.RolledOut(c, cfg.LogNs)
+	if err != nil {
+		return ret, err
+	}
+
+	syncers := certs.NewSecretSyncers(c, s, owner, cfg.LogNs, getHosts(cfg), rolledOut)
+	for _, sync := range syncers {
+		if err := syncer.Sync(context.TODO(), sync, e); err != nil {
+			return ret, err
+		}
+	}
+
+	// Server secret carries both server and CA certificates
+	server := syncers[1].Object().(*corev1.Secret)
+	next, err := certs.NextRotation(server)
+	if err != nil {
+		return ret, err
+	}
+
+	ret.checksum = certs.Checksum(server)
+	ret.requeueAfter = time.Until(next)
+	if certs.Rotating(syncers[0].Object().(*corev1.Secret)) {
+		ret.requeueAfter = rolloutRetry
+	}
+	return ret, nil
+}
@@ -0,0 +1,319 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package forward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Config defines how a client connects to a fluentd forward input
+type Config struct {
+	// Address of forward input as host:port
+	Address string
+	// SharedKey enables handshake with the server, it must match <security> of the input
+	SharedKey string
+	// SelfHostname is sent to the server during handshake. Defaults to os.Hostname().
+	SelfHostname string
+	// RequireAck makes every post wait for the server to acknowledge the chunk
+	RequireAck bool
+	// Timeout for connecting, writing and waiting for acknowledgements
+	Timeout time.Duration
+	// TLSConfig enables TLS, the input must have <transport tls>
+	TLSConfig *tls.Config
+}
+
+// Client sends records to a fluentd forward input. Connection is established on first post and
+// re-established after errors. Client is safe for concurrent use.
+type Client struct {
+	sync.Mutex
+	cfg  Config
+	conn net.Conn
+	rd   *msgp.Reader
+}
+
+// NewClient returns a new forward client
+func NewClient(cfg Config) *Client {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	if len(cfg.SelfHostname) == 0 {
+		cfg.SelfHostname, _ = os.Hostname()
+	}
+
+	return &Client{
+		cfg: cfg,
+	}
+}
+
+// Post sends a single record in Message mode
+func (c *Client) Post(tag string, ts time.Time, record map[string]interface{}) error {
+	et := EventTime(ts)
+	msg := msgp.AppendArrayHeader(nil, 4)
+	msg = msgp.AppendString(msg, tag)
+	msg, err := msgp.AppendExtension(msg, &et)
+	if err != nil {
+		return err
+	}
+	if msg, err = msgp.AppendIntf(msg, record); err != nil {
+		return err
+	}
+
+	return c.send(msg, 1)
+}
+
+// PostEntries sends records in the given mode, MessageMode sends one message per entry
+func (c *Client) PostEntries(mode Mode, tag string, entries []Entry) error {
+	if mode == MessageMode {
+		for _, e := range entries {
+			if err := c.Post(tag, e.Time, e.Record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	msg := msgp.AppendArrayHeader(nil, 3)
+	msg = msgp.AppendString(msg, tag)
+
+	var err error
+	switch mode {
+	case ForwardMode:
+		msg = msgp.AppendArrayHeader(msg, uint32(len(entries)))
+		for _, e := range entries {
+			if msg, err = appendEntry(msg, e); err != nil {
+				return err
+			}
+		}
+	case PackedForwardMode, CompressedPackedForwardMode:
+		var stream []byte
+		for _, e := range entries {
+			if stream, err = appendEntry(stream, e); err != nil {
+				return err
+			}
+		}
+		if mode == CompressedPackedForwardMode {
+			if stream, err = compress(stream); err != nil {
+				return err
+			}
+		}
+		msg = msgp.AppendBytes(msg, stream)
+	default:
+		return fmt.Errorf("Unsupported mode %s", mode)
+	}
+
+	return c.sendWithOption(msg, len(entries), mode == CompressedPackedForwardMode)
+}
+
+// Close closes connection to the server
+func (c *Client) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.closeConn()
+}
+
+// send appends option to msg, which is an array missing its last element, and writes it
+func (c *Client) send(msg []byte, size int) error {
+	return c.sendWithOption(msg, size, false)
+}
+
+func (c *Client) sendWithOption(msg []byte, size int, compressed bool) error {
+	chunk := ""
+	opts := map[string]interface{}{
+		"size": int64(size),
+	}
+	if compressed {
+		opts["compressed"] = "gzip"
+	}
+	if c.cfg.RequireAck {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		chunk = base64.StdEncoding.EncodeToString(id)
+		opts["chunk"] = chunk
+	}
+
+	msg, err := msgp.AppendIntf(msg, opts)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.write(msg); err != nil {
+		c.closeConn()
+		return err
+	}
+
+	if len(chunk) > 0 {
+		if err := c.waitAck(chunk); err != nil {
+			c.closeConn()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) write(msg []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.cfg.Timeout))
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+func (c *Client) waitAck(chunk string) error {
+	c.conn.SetReadDeadline(time.Now().Add(c.cfg.Timeout))
+	resp := map[string]interface{}{}
+	if err := c.rd.ReadMapStrIntf(resp); err != nil {
+		return err
+	}
+
+	if ack, _ := resp["ack"].(string); ack != chunk {
+		return fmt.Errorf("Unexpected ack %v, expected %s", resp["ack"], chunk)
+	}
+	return nil
+}
+
+func (c *Client) connect() error {
+	var conn net.Conn
+	var err error
+	if c.cfg.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: c.cfg.Timeout}, "tcp", c.cfg.Address, c.cfg.TLSConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", c.cfg.Address, c.cfg.Timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	c.rd = msgp.NewReader(conn)
+
+	if len(c.cfg.SharedKey) == 0 {
+		return nil
+	}
+
+	if err := c.handshake(); err != nil {
+		c.closeConn()
+		return err
+	}
+	return nil
+}
+
+// handshake answers HELO of the server with PING and verifies its PONG
+func (c *Client) handshake() error {
+	c.conn.SetReadDeadline(time.Now().Add(c.cfg.Timeout))
+	helo, err := c.rd.ReadIntf()
+	if err != nil {
+		return err
+	}
+
+	msg, ok := helo.([]interface{})
+	if !ok || len(msg) != 2 || msg[0] != heloType {
+		return fmt.Errorf("Expected HELO from server, got %v", helo)
+	}
+	heloOpts, _ := msg[1].(map[string]interface{})
+	nonce := toBytes(heloOpts["nonce"])
+
+	saltBytes := make([]byte, 16)
+	if _, err := rand.Read(saltBytes); err != nil {
+		return err
+	}
+	salt := hex.EncodeToString(saltBytes)
+
+	ping, err := msgp.AppendIntf(nil, []interface{}{pingType, c.cfg.SelfHostname, salt,
+		digest(salt, c.cfg.SelfHostname, nonce, c.cfg.SharedKey), "", ""})
+	if err != nil {
+		return err
+	}
+	if err := c.write(ping); err != nil {
+		return err
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(c.cfg.Timeout))
+	pong, err := c.rd.ReadIntf()
+	if err != nil {
+		return err
+	}
+
+	msg, ok = pong.([]interface{})
+	if !ok || len(msg) != 5 || msg[0] != pongType {
+		return fmt.Errorf("Expected PONG from server, got %v", pong)
+	}
+	if authenticated, _ := msg[1].(bool); !authenticated {
+		return fmt.Errorf("Authentication failed: %v", msg[2])
+	}
+
+	serverHostname, _ := msg[3].(string)
+	if msg[4] != digest(salt, serverHostname, nonce, c.cfg.SharedKey) {
+		return fmt.Errorf("Server %s failed to prove shared key", serverHostname)
+	}
+
+	return nil
+}
+
+func (c *Client) closeConn() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	c.rd = nil
+	return err
+}
+
+func compress(in []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(in); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// toBytes returns bin or str msgpack value as bytes
+func toBytes(v interface{}) []byte {
+	switch b := v.(type) {
+	case []byte:
+		return b
+	case string:
+		return []byte(b)
+	}
+	return nil
+}
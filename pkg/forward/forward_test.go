@@ -0,0 +1,147 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package forward_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/platform9/fluentd-operator/pkg/certs"
+	"github.com/platform9/fluentd-operator/pkg/forward"
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(t *testing.T, s *forward.Server, n int) []forward.Record {
+	ret := []forward.Record{}
+	for len(ret) < n {
+		select {
+		case r := <-s.Records():
+			ret = append(ret, r)
+		case err := <-s.Errors():
+			assert.FailNow(t, "server error", err.Error())
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "timed out waiting for records")
+		}
+	}
+	return ret
+}
+
+func getEntries() []forward.Entry {
+	ts := time.Unix(1577836800, 123456789)
+	return []forward.Entry{
+		{Time: ts, Record: map[string]interface{}{"log": "first", "kubernetes": map[string]interface{}{"namespace_name": "default"}}},
+		{Time: ts.Add(time.Second), Record: map[string]interface{}{"log": "second"}},
+	}
+}
+
+func TestModes(t *testing.T) {
+	modes := []forward.Mode{forward.MessageMode, forward.ForwardMode, forward.PackedForwardMode,
+		forward.CompressedPackedForwardMode}
+
+	for _, requireAck := range []bool{false, true} {
+		s, err := forward.NewTestServer(forward.ServerConfig{})
+		assert.Nil(t, err)
+
+		c := forward.NewClient(forward.Config{Address: s.Addr(), RequireAck: requireAck})
+		for _, mode := range modes {
+			entries := getEntries()
+			assert.Nil(t, c.PostEntries(mode, "kube.test", entries), mode.String())
+
+			records := receive(t, s, len(entries))
+			for i, r := range records {
+				assert.Equal(t, "kube.test", r.Tag)
+				assert.Equal(t, mode, r.Mode)
+				assert.True(t, entries[i].Time.Equal(r.Time), mode.String())
+				assert.Equal(t, entries[i].Record["log"], r.Record["log"])
+			}
+			nested := records[0].Record["kubernetes"].(map[string]interface{})
+			assert.Equal(t, "default", nested["namespace_name"])
+		}
+
+		assert.Nil(t, c.Close())
+		assert.Nil(t, s.Close())
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	s, err := forward.NewTestServer(forward.ServerConfig{SharedKey: "secret"})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	c := forward.NewClient(forward.Config{Address: s.Addr(), SharedKey: "secret", RequireAck: true})
+	defer c.Close()
+
+	assert.Nil(t, c.Post("k8s.events.default", time.Now(), map[string]interface{}{"reason": "BackOff"}))
+	r := receive(t, s, 1)[0]
+	assert.Equal(t, "BackOff", r.Record["reason"])
+}
+
+func TestHandshakeFailure(t *testing.T) {
+	s, err := forward.NewTestServer(forward.ServerConfig{SharedKey: "secret"})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	c := forward.NewClient(forward.Config{Address: s.Addr(), SharedKey: "wrong"})
+	defer c.Close()
+
+	assert.NotNil(t, c.Post("kube.test", time.Now(), map[string]interface{}{}))
+	select {
+	case err := <-s.Errors():
+		assert.Contains(t, err.Error(), "failed authentication")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "server did not reject client")
+	}
+}
+
+func TestTLS(t *testing.T) {
+	ca, err := certs.NewCA("test-ca", time.Hour)
+	assert.Nil(t, err)
+	cert, err := certs.NewServerCert(ca, "fluentd", []string{"fluentd", "127.0.0.1"}, time.Hour)
+	assert.Nil(t, err)
+	pair, err := tls.X509KeyPair(cert.Cert, cert.Key)
+	assert.Nil(t, err)
+
+	s, err := forward.NewTestServer(forward.ServerConfig{
+		SharedKey: "secret",
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{pair}},
+	})
+	assert.Nil(t, err)
+	defer s.Close()
+
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM(ca.Cert))
+	c := forward.NewClient(forward.Config{Address: s.Addr(), SharedKey: "secret", RequireAck: true,
+		TLSConfig: &tls.Config{RootCAs: roots, ServerName: "fluentd"}})
+	defer c.Close()
+
+	assert.Nil(t, c.PostEntries(forward.PackedForwardMode, "kube.test", getEntries()))
+	assert.Len(t, receive(t, s, 2), 2)
+
+	// Clients not trusting the CA are refused
+	untrusted := forward.NewClient(forward.Config{Address: s.Addr(), Timeout: time.Second,
+		TLSConfig: &tls.Config{ServerName: "fluentd"}})
+	assert.NotNil(t, untrusted.Post("kube.test", time.Now(), map[string]interface{}{}))
+}
+
+func TestUnreachableServer(t *testing.T) {
+	s, err := forward.NewTestServer(forward.ServerConfig{})
+	assert.Nil(t, err)
+	addr := s.Addr()
+	s.Close()
+
+	c := forward.NewClient(forward.Config{Address: addr, Timeout: time.Second})
+	assert.NotNil(t, c.Post("kube.test", time.Now(), map[string]interface{}{}))
+}
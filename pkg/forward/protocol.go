@@ -0,0 +1,164 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package forward implements fluentd forward protocol v1, a client to send records to fluentd and
+// an in-process server to inspect records in tests.
+// See https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1
+package forward
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// Mode is the way entries are carried in a forward message
+type Mode int
+
+const (
+	// MessageMode carries a single entry: [tag, time, record, option]
+	MessageMode Mode = iota
+	// ForwardMode carries an array of entries: [tag, [[time, record], ...], option]
+	ForwardMode
+	// PackedForwardMode carries entries as concatenated msgpack stream: [tag, bin, option]
+	PackedForwardMode
+	// CompressedPackedForwardMode is PackedForwardMode with the stream gzipped
+	CompressedPackedForwardMode
+)
+
+func (m Mode) String() string {
+	switch m {
+	case MessageMode:
+		return "Message"
+	case ForwardMode:
+		return "Forward"
+	case PackedForwardMode:
+		return "PackedForward"
+	case CompressedPackedForwardMode:
+		return "CompressedPackedForward"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+const (
+	eventTimeExt = 0
+	heloType     = "HELO"
+	pingType     = "PING"
+	pongType     = "PONG"
+)
+
+func init() {
+	msgp.RegisterExtension(eventTimeExt, func() msgp.Extension { return new(EventTime) })
+}
+
+// EventTime is the msgpack extension type 0 carrying time with nanosecond precision
+type EventTime time.Time
+
+// ExtensionType implements msgp.Extension
+func (t *EventTime) ExtensionType() int8 {
+	return eventTimeExt
+}
+
+// Len implements msgp.Extension
+func (t *EventTime) Len() int {
+	return 8
+}
+
+// MarshalBinaryTo implements msgp.Extension
+func (t *EventTime) MarshalBinaryTo(b []byte) error {
+	tm := time.Time(*t)
+	binary.BigEndian.PutUint32(b, uint32(tm.Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(tm.Nanosecond()))
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension
+func (t *EventTime) UnmarshalBinary(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("Invalid EventTime length %d", len(b))
+	}
+	sec := binary.BigEndian.Uint32(b)
+	nsec := binary.BigEndian.Uint32(b[4:])
+	*t = EventTime(time.Unix(int64(sec), int64(nsec)))
+	return nil
+}
+
+// Entry is a single record with its timestamp
+type Entry struct {
+	Time   time.Time
+	Record map[string]interface{}
+}
+
+func appendEntry(b []byte, e Entry) ([]byte, error) {
+	et := EventTime(e.Time)
+	b = msgp.AppendArrayHeader(b, 2)
+	b, err := msgp.AppendExtension(b, &et)
+	if err != nil {
+		return b, err
+	}
+	return msgp.AppendIntf(b, e.Record)
+}
+
+// readTime reads either an integer or an EventTime timestamp
+func readTime(rd *msgp.Reader) (time.Time, error) {
+	t, err := rd.NextType()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch t {
+	case msgp.IntType:
+		sec, err := rd.ReadInt64()
+		return time.Unix(sec, 0), err
+	case msgp.UintType:
+		sec, err := rd.ReadUint64()
+		return time.Unix(int64(sec), 0), err
+	case msgp.ExtensionType:
+		var et EventTime
+		err := rd.ReadExtension(&et)
+		return time.Time(et), err
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid time type %s", t)
+}
+
+// readEntry reads [time, record]
+func readEntry(rd *msgp.Reader) (Entry, error) {
+	e := Entry{Record: map[string]interface{}{}}
+	sz, err := rd.ReadArrayHeader()
+	if err != nil {
+		return e, err
+	}
+	if sz != 2 {
+		return e, fmt.Errorf("Invalid entry size %d", sz)
+	}
+
+	if e.Time, err = readTime(rd); err != nil {
+		return e, err
+	}
+	return e, rd.ReadMapStrIntf(e.Record)
+}
+
+// digest returns hex encoded sha512 used by handshake to prove knowledge of the shared key
+func digest(salt, hostname string, nonce []byte, sharedKey string) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(sharedKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package forward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	testServerHostname = "forward-test-server"
+	recordsBuffer      = 1024
+)
+
+// Record is an entry received by the test server
+type Record struct {
+	Entry
+	Tag  string
+	Mode Mode
+}
+
+// ServerConfig defines behaviour of the test server
+type ServerConfig struct {
+	// SharedKey requires clients to complete handshake before sending records
+	SharedKey string
+	// TLSConfig makes the server accept TLS connections only
+	TLSConfig *tls.Config
+}
+
+// Server is an in-process forward input which decodes all modes, answers acks and handshakes,
+// and publishes received records. It is meant for tests and listens on a random local port.
+type Server struct {
+	cfg      ServerConfig
+	listener net.Listener
+	records  chan Record
+	errors   chan error
+	wg       sync.WaitGroup
+}
+
+// NewTestServer starts a new test server on 127.0.0.1
+func NewTestServer(cfg ServerConfig) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLSConfig != nil {
+		l = tls.NewListener(l, cfg.TLSConfig)
+	}
+
+	s := &Server{
+		cfg:      cfg,
+		listener: l,
+		records:  make(chan Record, recordsBuffer),
+		errors:   make(chan error, recordsBuffer),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns host:port the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Records returns channel of received records
+func (s *Server) Records() <-chan Record {
+	return s.records
+}
+
+// Errors returns channel of protocol errors, such as failed handshakes or malformed messages
+func (s *Server) Errors() <-chan error {
+	return s.errors
+}
+
+// Close stops accepting connections
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := msgp.NewReader(conn)
+
+	if len(s.cfg.SharedKey) > 0 {
+		if err := s.handshake(conn, rd); err != nil {
+			s.errors <- err
+			return
+		}
+	}
+
+	for {
+		if err := s.readMessage(conn, rd); err != nil {
+			if err != io.EOF {
+				s.errors <- err
+			}
+			return
+		}
+	}
+}
+
+func (s *Server) handshake(conn net.Conn, rd *msgp.Reader) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	helo, err := msgp.AppendIntf(nil, []interface{}{heloType, map[string]interface{}{
+		"nonce":     nonce,
+		"auth":      []byte{},
+		"keepalive": true,
+	}})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(helo); err != nil {
+		return err
+	}
+
+	ping, err := rd.ReadIntf()
+	if err != nil {
+		return err
+	}
+
+	msg, ok := ping.([]interface{})
+	if !ok || len(msg) != 6 || msg[0] != pingType {
+		return fmt.Errorf("Expected PING from client, got %v", ping)
+	}
+	hostname, _ := msg[1].(string)
+	salt := string(toBytes(msg[2]))
+
+	authenticated := msg[3] == digest(salt, hostname, nonce, s.cfg.SharedKey)
+	reason := ""
+	if !authenticated {
+		reason = "shared_key mismatch"
+	}
+
+	pong, err := msgp.AppendIntf(nil, []interface{}{pongType, authenticated, reason, testServerHostname,
+		digest(salt, testServerHostname, nonce, s.cfg.SharedKey)})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(pong); err != nil {
+		return err
+	}
+
+	if !authenticated {
+		return fmt.Errorf("Client %s failed authentication", hostname)
+	}
+	return nil
+}
+
+func (s *Server) readMessage(conn net.Conn, rd *msgp.Reader) error {
+	sz, err := rd.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	if sz < 2 || sz > 4 {
+		return fmt.Errorf("Invalid message size %d", sz)
+	}
+
+	tag, err := rd.ReadString()
+	if err != nil {
+		return err
+	}
+
+	t, err := rd.NextType()
+	if err != nil {
+		return err
+	}
+
+	var mode Mode
+	var entries []Entry
+	var stream []byte
+	read := uint32(2)
+	switch t {
+	case msgp.ArrayType:
+		mode = ForwardMode
+		entries, err = readEntries(rd)
+	case msgp.BinType:
+		mode = PackedForwardMode
+		stream, err = rd.ReadBytes(nil)
+	case msgp.StrType:
+		mode = PackedForwardMode
+		stream, err = rd.ReadStringAsBytes(nil)
+	default:
+		mode = MessageMode
+		e := Entry{Record: map[string]interface{}{}}
+		if e.Time, err = readTime(rd); err == nil {
+			err = rd.ReadMapStrIntf(e.Record)
+		}
+		entries = []Entry{e}
+		read = 3
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := map[string]interface{}{}
+	if read < sz {
+		if err := rd.ReadMapStrIntf(opts); err != nil {
+			return err
+		}
+	}
+
+	if mode == PackedForwardMode {
+		if opts["compressed"] == "gzip" {
+			mode = CompressedPackedForwardMode
+			if stream, err = decompress(stream); err != nil {
+				return err
+			}
+		}
+		if entries, err = readPacked(stream); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range entries {
+		s.records <- Record{Entry: e, Tag: tag, Mode: mode}
+	}
+
+	if chunk, ok := opts["chunk"]; ok {
+		ack, err := msgp.AppendIntf(nil, map[string]interface{}{"ack": chunk})
+		if err != nil {
+			return err
+		}
+		_, err = conn.Write(ack)
+		return err
+	}
+
+	return nil
+}
+
+func readEntries(rd *msgp.Reader) ([]Entry, error) {
+	n, err := rd.ReadArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for i := uint32(0); i < n; i++ {
+		e, err := readEntry(rd)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// readPacked reads concatenated [time, record] entries
+func readPacked(stream []byte) ([]Entry, error) {
+	rd := msgp.NewReader(bytes.NewReader(stream))
+	entries := []Entry{}
+	for {
+		e, err := readEntry(rd)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+func decompress(in []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines operator metrics. They are registered with controller-runtime registry and served
+// by the manager along with its own metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "fluentd_operator"
+
+	// ResultSuccess labels successful reloads
+	ResultSuccess = "success"
+	// ResultFailure labels failed reloads
+	ResultFailure = "failure"
+)
+
+var (
+	// RenderErrors counts failures to render an output into fluentd configuration
+	RenderErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "render_errors_total",
+		Help:      "Number of failures to render an output into fluentd configuration",
+	}, []string{"output"})
+
+	// Reloads counts fluentd configuration reloads by result
+	Reloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reload_total",
+		Help:      "Number of fluentd configuration reloads",
+	}, []string{"result"})
+
+	// ReloadDuration observes how long fluentd takes to answer a reload
+	ReloadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reload_duration_seconds",
+		Help:      "Latency of fluentd configuration reloads",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+
+	// Outputs is the number of Output objects by type
+	Outputs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outputs",
+		Help:      "Number of Output objects by type",
+	}, []string{"type"})
+
+	// ConfigSize is the size of the last rendered fluentd configuration
+	ConfigSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_size_bytes",
+		Help:      "Size of the last rendered fluentd configuration",
+	})
+
+	// LastApply is when fluentd last reloaded configuration successfully
+	LastApply = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_apply_timestamp_seconds",
+		Help:      "Unix time of the last successful fluentd configuration reload",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(RenderErrors, Reloads, ReloadDuration, Outputs, ConfigSize, LastApply)
+}
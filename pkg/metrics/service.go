@@ -0,0 +1,117 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var log = logf.Log.WithName("metrics")
+
+// PortName is the name of metrics port in the operator service
+const PortName = "metrics"
+
+var (
+	// ServiceMonitorGVK is the prometheus-operator kind scraping endpoints of a service
+	ServiceMonitorGVK = schema.GroupVersionKind{
+		Group:   "monitoring.coreos.com",
+		Version: "v1",
+		Kind:    "ServiceMonitor",
+	}
+	// PodMonitorGVK is the prometheus-operator kind scraping pods directly
+	PodMonitorGVK = schema.GroupVersionKind{
+		Group:   "monitoring.coreos.com",
+		Version: "v1",
+		Kind:    "PodMonitor",
+	}
+)
+
+// HasKind returns whether the cluster serves kind gvk, such as monitors of prometheus-operator
+func HasKind(mapper meta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Expose creates a service in namespace ns for the operator pods, which are labeled name=<name>, and a
+// ServiceMonitor scraping it if prometheus-operator is installed.
+func Expose(c client.Client, mapper meta.RESTMapper, ns, name string, port int32) error {
+	labels := map[string]string{
+		"name": name,
+	}
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-metrics", name),
+			Namespace: ns,
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), c, svc, func() error {
+		svc.ObjectMeta.Labels = labels
+		svc.Spec.Selector = labels
+		svc.Spec.Ports = []corev1.ServicePort{{
+			Name:       PortName,
+			Port:       port,
+			Protocol:   corev1.ProtocolTCP,
+			TargetPort: intstr.FromInt(int(port)),
+		}}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if ok, err := HasKind(mapper, ServiceMonitorGVK); !ok {
+		if err == nil {
+			log.Info("ServiceMonitor is not available, skipping")
+		}
+		return err
+	}
+
+	sm := &unstructured.Unstructured{}
+	sm.SetGroupVersionKind(ServiceMonitorGVK)
+	sm.SetName(svc.Name)
+	sm.SetNamespace(ns)
+	_, err = controllerutil.CreateOrUpdate(context.TODO(), c, sm, func() error {
+		sm.SetLabels(labels)
+		return unstructured.SetNestedField(sm.Object, map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"name": name,
+				},
+			},
+			"endpoints": []interface{}{
+				map[string]interface{}{
+					"port": PortName,
+				},
+			},
+		}, "spec")
+	})
+	return err
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestExpose(t *testing.T) {
+	key := types.NamespacedName{Namespace: "pf9-operators", Name: "fluentd-operator-metrics"}
+
+	// Without prometheus-operator only the service is created
+	c := fake.NewFakeClient()
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{})
+	assert.Nil(t, Expose(c, mapper, "pf9-operators", "fluentd-operator", 60000))
+
+	svc := &corev1.Service{}
+	assert.Nil(t, c.Get(context.TODO(), key, svc))
+	assert.Equal(t, "fluentd-operator", svc.Spec.Selector["name"])
+	assert.Equal(t, int32(60000), svc.Spec.Ports[0].Port)
+
+	mapper.Add(ServiceMonitorGVK, meta.RESTScopeNamespace)
+	assert.Nil(t, Expose(c, mapper, "pf9-operators", "fluentd-operator", 60000))
+
+	sm := &unstructured.Unstructured{}
+	sm.SetGroupVersionKind(ServiceMonitorGVK)
+	assert.Nil(t, c.Get(context.TODO(), key, sm))
+	port, _, _ := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+	assert.Equal(t, PortName, port[0].(map[string]interface{})["port"])
+}
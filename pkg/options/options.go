@@ -11,6 +11,8 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+// Package options defines operator settings. Components receive them as a Config rather than reading
+// command line flags, so that differently configured operators can run in one process.
 package options
 
 import (
@@ -20,29 +22,95 @@ import (
 const (
 	defaultLogNs          = "logging"
 	defaultFluentSvcAct   = "fluent"
-	defaultFluentbitImage = "fluent/fluent-bit:1.0.6"
-	defaultFluentdImage   = "platform9/fluentd:v1.1"
-	defaultCfgDir         = "etc/conf"
+	defaultFluentbitImage = "fluent/fluent-bit:1.8.15"
+	defaultFluentdImage   = "platform9/fluentd:v1.3"
 	defaultFwdPort        = 62073
 	defaultReloadPort     = 45550
 	defaultReloadHost     = "fluentd.logging.svc.cluster.local"
+	defaultRuntime        = "auto"
+	defaultFwdHost        = "fluentd.logging.svc.cluster.local"
+	defaultMetricsPort    = 60000
+	defaultFdMetricsPort  = 24231
 )
 
-var (
+// Config holds settings of the operator
+type Config struct {
 	// LogNs is the namespace name for running fluent components
-	LogNs = flag.String("log-ns", defaultLogNs, "Namespace for running fluent-bit and fluentd")
+	LogNs string
 	// SvcAcct is the service account for running fluent components
-	SvcAcct = flag.String("svc-acct", defaultFluentSvcAct, "Service account to use for fluentd and fluentbit")
+	SvcAcct string
 	// FluentbitImage points to container image for running fluentbit
-	FluentbitImage = flag.String("fluentbit-image", defaultFluentbitImage, "Fluentbit image")
+	FluentbitImage string
 	// FluentdImage points to container image for running fluentd
-	FluentdImage = flag.String("fluentd-image", defaultFluentdImage, "Fluentd image")
-	// CfgDir is the directory local to operator, which contains initial configuration of fluentd and fluentbit
-	CfgDir = flag.String("cfg-dir", defaultCfgDir, "Config directory")
+	FluentdImage string
+	// CfgDir is the directory local to operator, which overrides initial configuration of fluent-bit embedded
+	// in the operator. Defaults are used when empty.
+	CfgDir string
 	// ForwardPort is fluentd port to which fluent-bit forwards logs
-	ForwardPort = flag.Int("fwd-port", defaultFwdPort, "Forwarding port for fluentd")
+	ForwardPort int
 	// ReloadPort is fluentd port used to reload fluentd config
-	ReloadPort = flag.Int("reload-port", defaultReloadPort, "Fluentd config reload port")
+	ReloadPort int
 	// ReloadHost refers to fluentd reload webhook
-	ReloadHost = flag.String("reload-host", defaultReloadHost, "Fluentd reload host")
-)
+	ReloadHost string
+	// ContainerRuntime selects the log layout fluent-bit reads on nodes
+	ContainerRuntime string
+	// Multiline lists languages whose stack traces fluent-bit joins into a single record, each optionally limited
+	// to pods of a namespace, e.g. java:payments/api-*
+	Multiline string
+	// JournaldUnits lists systemd units whose journal fluent-bit collects
+	JournaldUnits string
+	// AuditLog is the kube-apiserver audit log file collected from control plane nodes
+	AuditLog string
+	// ForwardHost refers to fluentd forward input
+	ForwardHost string
+	// ExportEvents enables forwarding kubernetes events to fluentd
+	ExportEvents bool
+	// FlushOnDelete flushes fluentd buffers before an output being deleted is removed from configuration
+	FlushOnDelete bool
+	// SecureForward enables TLS and shared key authentication on fluentd forward input
+	SecureForward bool
+	// MetricsPort is the port operator serves prometheus metrics on
+	MetricsPort int
+	// FluentdMetricsPort is the port fluentd serves prometheus metrics on
+	FluentdMetricsPort int
+}
+
+// New returns Config with default settings
+func New() *Config {
+	return &Config{
+		LogNs:              defaultLogNs,
+		SvcAcct:            defaultFluentSvcAct,
+		FluentbitImage:     defaultFluentbitImage,
+		FluentdImage:       defaultFluentdImage,
+		ForwardPort:        defaultFwdPort,
+		ReloadPort:         defaultReloadPort,
+		ReloadHost:         defaultReloadHost,
+		ContainerRuntime:   defaultRuntime,
+		ForwardHost:        defaultFwdHost,
+		MetricsPort:        defaultMetricsPort,
+		FluentdMetricsPort: defaultFdMetricsPort,
+		FlushOnDelete:      true,
+	}
+}
+
+// AddFlags binds settings to command line flags of fs, current values being the defaults
+func (c *Config) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.LogNs, "log-ns", c.LogNs, "Namespace for running fluent-bit and fluentd")
+	fs.StringVar(&c.SvcAcct, "svc-acct", c.SvcAcct, "Service account to use for fluentd and fluentbit")
+	fs.StringVar(&c.FluentbitImage, "fluentbit-image", c.FluentbitImage, "Fluentbit image")
+	fs.StringVar(&c.FluentdImage, "fluentd-image", c.FluentdImage, "Fluentd image")
+	fs.StringVar(&c.CfgDir, "cfg-dir", c.CfgDir, "Config directory overriding embedded defaults, see -dump-config")
+	fs.IntVar(&c.ForwardPort, "fwd-port", c.ForwardPort, "Forwarding port for fluentd")
+	fs.IntVar(&c.ReloadPort, "reload-port", c.ReloadPort, "Fluentd config reload port")
+	fs.StringVar(&c.ReloadHost, "reload-host", c.ReloadHost, "Fluentd reload host")
+	fs.StringVar(&c.ContainerRuntime, "container-runtime", c.ContainerRuntime, "Container runtime on nodes: docker, containerd, cri-o or auto")
+	fs.StringVar(&c.Multiline, "multiline", c.Multiline, "Comma separated languages to join stack traces for: go, java, python, ruby, optionally limited to pods as language:namespace[/pod-pattern]")
+	fs.StringVar(&c.JournaldUnits, "journald-units", c.JournaldUnits, "Comma separated systemd units to collect from journald, e.g. kubelet.service")
+	fs.StringVar(&c.AuditLog, "audit-log", c.AuditLog, "Path of kube-apiserver audit log on control plane nodes, e.g. /var/log/kube-apiserver-audit.log")
+	fs.StringVar(&c.ForwardHost, "fwd-host", c.ForwardHost, "Fluentd forward host")
+	fs.BoolVar(&c.ExportEvents, "export-events", c.ExportEvents, "Forward kubernetes events to fluentd with tag k8s.events.<namespace>")
+	fs.BoolVar(&c.FlushOnDelete, "flush-on-delete", c.FlushOnDelete, "Flush fluentd buffers of a deleted output before removing it from configuration")
+	fs.BoolVar(&c.SecureForward, "secure-forward", c.SecureForward, "Require TLS and shared key from clients of fluentd forward input")
+	fs.IntVar(&c.MetricsPort, "metrics-port", c.MetricsPort, "Port to serve operator metrics on")
+	fs.IntVar(&c.FluentdMetricsPort, "fluentd-metrics-port", c.FluentdMetricsPort, "Port for fluentd to serve prometheus metrics on")
+}
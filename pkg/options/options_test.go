@@ -0,0 +1,37 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlags(t *testing.T) {
+	cfg := options.New()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.AddFlags(fs)
+
+	assert.Nil(t, fs.Parse([]string{"-log-ns", "logs", "-secure-forward", "-fwd-port", "24224"}))
+	assert.Equal(t, "logs", cfg.LogNs)
+	assert.True(t, cfg.SecureForward)
+	assert.Equal(t, 24224, cfg.ForwardPort)
+
+	// Unset flags keep defaults, other configs are not affected
+	assert.Equal(t, options.New().FluentdImage, cfg.FluentdImage)
+	assert.Equal(t, "logging", options.New().LogNs)
+}
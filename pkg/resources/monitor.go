@@ -0,0 +1,57 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/platform9/fluentd-operator/pkg/options"
+)
+
+// MetricsPath is where fluentd serves prometheus metrics
+const MetricsPath = "/metrics"
+
+// Monitor represents sources exposing fluentd metrics to prometheus, through fluent-plugin-prometheus.
+type Monitor struct {
+	port int
+}
+
+// NewMonitor returns a new monitor object
+func NewMonitor(cfg *options.Config) *Monitor {
+	return &Monitor{
+		port: cfg.FluentdMetricsPort,
+	}
+}
+
+// Render returns byte array representing fluentd configuration of metrics sources
+func (m *Monitor) Render() ([]byte, error) {
+	var ret bytes.Buffer
+	fmt.Fprintf(&ret, "<source>")
+	fmt.Fprintf(&ret, "\n    @type prometheus")
+	fmt.Fprintf(&ret, "\n    bind 0.0.0.0")
+	fmt.Fprintf(&ret, "\n    port %d", m.port)
+	fmt.Fprintf(&ret, "\n    metrics_path %s", MetricsPath)
+	fmt.Fprintf(&ret, "\n</source>")
+
+	// Buffer and retry metrics of every output plugin
+	fmt.Fprintf(&ret, "\n\n<source>")
+	fmt.Fprintf(&ret, "\n    @type prometheus_monitor")
+	fmt.Fprintf(&ret, "\n</source>")
+	fmt.Fprintf(&ret, "\n\n<source>")
+	fmt.Fprintf(&ret, "\n    @type prometheus_output_monitor")
+	fmt.Fprintf(&ret, "\n</source>")
+
+	return ret.Bytes(), nil
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/pkg/resources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorRender(t *testing.T) {
+	buf, err := resources.NewMonitor(options.New()).Render()
+	assert.Nil(t, err)
+
+	conf := string(buf)
+	assert.Equal(t, 3, strings.Count(conf, "<source>"))
+	assert.Contains(t, conf, "@type prometheus\n    bind 0.0.0.0\n    port 24231\n    metrics_path /metrics")
+	assert.Contains(t, conf, "@type prometheus_monitor")
+	assert.Contains(t, conf, "@type prometheus_output_monitor")
+}
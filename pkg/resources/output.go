@@ -16,8 +16,13 @@ package resources
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
@@ -26,151 +31,272 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// ConfigDir is where fluentd configuration map is mounted, along with the secret of files referenced by outputs
+	ConfigDir = "/fluentd/etc"
+	// ConfigFile is the fluentd configuration file in ConfigDir
+	ConfigFile = "fluent.conf"
+
+	// routedTags selects records sent to outputs: logs of containers and, if collected, of node services and
+	// kubernetes events
+	routedTags = "kube.** node.** k8s.events.**"
+)
+
 // Output implements the Resource interface for type "output"
 type Output struct {
-	client     client.Client
+	client     client.Reader
 	obj        *v1alpha1.Output
 	paramCache map[string]string
+	// sections are nested directives of the output, such as <format> or <buffer>
+	sections []section
+	// defaults are parameters of the output type which the output doesn't set
+	defaults []v1alpha1.Param
+	// files are referenced by the output, such as TLS certificates, by name in ConfigDir
+	files map[string][]byte
+	// setup is installed by the operator in elasticsearch or opensearch cluster of the output
+	setup *SearchSetup
+}
+
+// section is a nested directive of an output, e.g. <buffer tag> where name is "buffer" and arg is "tag"
+type section struct {
+	name     string
+	arg      string
+	params   map[string]string
+	sections []section
 }
 
 // NewOutput returns a new output resource
-func NewOutput(c client.Client, in *v1alpha1.Output) *Output {
+func NewOutput(c client.Reader, in *v1alpha1.Output) *Output {
 	return &Output{
 		client:     c,
 		obj:        in,
 		paramCache: map[string]string{},
+		files:      map[string][]byte{},
 	}
 }
 
 // Render returns byte array representing fluentd configuration for an output object
 func (o *Output) Render() ([]byte, error) {
-	validTypes := map[string]bool{
-		"stdout":        true,
-		"elasticsearch": true,
-		"loki":          true,
-		"s3":            true,
-		"ender":         true,
+	params, err := o.renderParams()
+	if err != nil {
+		return []byte{}, err
 	}
 	outputType := strings.ToLower(o.obj.Spec.Type)
 
-	if _, ok := validTypes[outputType]; !ok {
-		// TODO: Build error handling
-		return []byte{}, fmt.Errorf("Invalid type: %s", o.obj.Spec.Type)
+	// Every output gets its own label, so records reach all outputs instead of the first match
+	var ret bytes.Buffer
+	fmt.Fprintf(&ret, "<label %s>", o.label())
+	if f := o.filter(outputType); f != nil {
+		writeSection(&ret, *f, "    ")
 	}
+	writeSection(&ret, section{name: "match", arg: routedTags, params: params, sections: o.sections}, "    ")
 
-	params := map[string]string{}
-	var err error
-	switch outputType {
-	case "elasticsearch":
-		if params, err = o.getEsParams(); err != nil {
-			return []byte{}, err
-		}
-	case "loki":
-		if params, err = o.getLokiParams(); err != nil {
-			return []byte{}, err
-		}
-	case "s3":
-		if params, err = o.getS3Params(); err != nil {
-			return []byte{}, err
-		}
+	// Always append null match in the end
+	fmt.Fprintf(&ret, "\n    <match **>")
+	fmt.Fprintf(&ret, "\n        @type null")
+	fmt.Fprintf(&ret, "\n    </match>")
+	fmt.Fprintf(&ret, "\n</label>")
+	return ret.Bytes(), nil
+}
+
+// writeSection writes section s and sections nested in it, indented by indent
+func writeSection(buf *bytes.Buffer, s section, indent string) {
+	fmt.Fprintf(buf, "\n%s<%s>", indent, strings.TrimSpace(s.name+" "+s.arg))
+	writeParams(buf, s.params, indent+"    ")
+	for _, nested := range s.sections {
+		writeSection(buf, nested, indent+"    ")
 	}
+	fmt.Fprintf(buf, "\n%s</%s>", indent, s.name)
+}
 
-	var ret bytes.Buffer
-	fmt.Fprintf(&ret, "<match kube.**>")
-	for k, v := range params {
-		fmt.Fprintf(&ret, "\n    %s %s", k, v)
+// writeParams writes params sorted by name, so that configuration is the same for the same outputs
+func writeParams(buf *bytes.Buffer, params map[string]string, indent string) {
+	keys := []string{}
+	for k := range params {
+		keys = append(keys, k)
 	}
-	fmt.Fprintf(&ret, "\n</match>")
+	sort.Strings(keys)
 
-	// Always append null match in the end
-	fmt.Fprintf(&ret, "\n<match **>")
-	fmt.Fprintf(&ret, "\n    @type null")
-	fmt.Fprintf(&ret, "\n</match>")
-	return ret.Bytes(), nil
+	for _, k := range keys {
+		fmt.Fprintf(buf, "\n%s%s %s", indent, k, params[k])
+	}
 }
 
-func (o *Output) getEsParams() (map[string]string, error) {
-	indexName := fmt.Sprintf("fluentd-%s", o.obj.Name)
-	params := map[string]string{}
+// Name returns name of the Output object
+func (o *Output) Name() string {
+	return o.obj.Name
+}
 
-	params["@type"] = "elasticsearch"
+// Files returns files referenced by the output, by name in ConfigDir. They are known once it is rendered.
+func (o *Output) Files() map[string][]byte {
+	return o.files
+}
 
-	var err error
+// getParams returns parameters of the output, with values of references to secrets resolved. Parameters named
+// in fileParams are stored in files, which they refer to by path.
+func (o *Output) getParams(fileParams ...string) (map[string]string, error) {
+	isFile := map[string]bool{}
+	for _, name := range fileParams {
+		isFile[name] = true
+	}
 
-	for _, p := range o.obj.Spec.Params {
+	params := map[string]string{}
+	for _, p := range o.params() {
 		name := strings.ToLower(p.Name)
+		if isFile[name] {
+			data, err := o.getRaw(&p)
+			if err != nil {
+				return map[string]string{}, err
+			}
+
+			file := fmt.Sprintf("%s.%s", o.obj.Name, name)
+			o.files[file] = data
+			params[name] = path.Join(ConfigDir, file)
+			continue
+		}
+
 		v := p.Value
 		if len(v) == 0 {
+			var err error
 			if v, err = o.getValueFrom(&p.ValueFrom); err != nil {
 				return map[string]string{}, err
 			}
 		}
-
 		params[name] = v
 	}
 
-	if _, ok := params["index_name"]; !ok {
-		params["index_name"] = indexName
+	return params, nil
+}
+
+// label returns name of fluentd label which routes records to this output
+func (o *Output) label() string {
+	return fmt.Sprintf("@output-%s", o.obj.Name)
+}
+
+// filter returns a grep filter selecting records of the output namespace, or nil if the output isn't scoped.
+// Outputs printing to stdout leave out records of fluentd, which would otherwise be collected again.
+func (o *Output) filter(outputType string) *section {
+	grep := section{name: "filter", arg: "**", params: map[string]string{"@type": "grep"}}
+	if o.obj.Spec.Namespace != "" {
+		grep.sections = append(grep.sections, section{name: "regexp", params: map[string]string{
+			"key":     "$.kubernetes.namespace_name",
+			"pattern": fmt.Sprintf("/^%s$/", regexp.QuoteMeta(o.obj.Spec.Namespace)),
+		}})
+	}
+	if outputType == "stdout" {
+		grep.sections = append(grep.sections, section{name: "exclude", params: map[string]string{
+			"key":     "$.kubernetes.labels.k8s-app",
+			"pattern": "/^fluentd$/",
+		}})
 	}
 
-	if v, ok := params["url"]; ok {
-		u, err := url.Parse(v)
-		if err != nil {
-			return map[string]string{}, err
-		}
-		if u.Port() != "" {
-			params["port"] = u.Port()
-		}
-		if u.Hostname() != "" {
-			params["host"] = u.Hostname()
-		}
-		if u.Scheme != "" {
-			params["scheme"] = u.Scheme
-		}
-		delete(params, "url")
-	} else {
-		params["host"] = "elasticsearch"
-		params["port"] = "9200"
-		params["scheme"] = "http"
+	if len(grep.sections) == 0 {
+		return nil
+	}
+	return &grep
+}
+
+func (o *Output) getStdoutParams() (map[string]string, error) {
+	params, err := o.getParams()
+	if err != nil {
+		return map[string]string{}, err
 	}
+	params["@type"] = "stdout"
+
 	return params, nil
 }
 
-func (o *Output) getLokiParams() (map[string]string, error) {
-	params := map[string]string{}
+func (o *Output) getFileParams() (map[string]string, error) {
+	params, err := o.getParams()
+	if err != nil {
+		return map[string]string{}, err
+	}
+	params["@type"] = "file"
+
+	return params, nil
+}
+
+func (o *Output) getEsParams() (map[string]string, error) {
+	return o.getSearchParams(elasticsearch)
+}
+
+func (o *Output) getOpenSearchParams() (map[string]string, error) {
+	return o.getSearchParams(opensearch)
+}
+
+// lokiFileParams are credentials of loki output, which the plugin reads from files
+var lokiFileParams = []string{"bearer_token_file", "cert", "key", "ca_cert"}
+
+// lokiLabelPrefix prefixes parameters which map a record field to a stream label, e.g. label.namespace
+const lokiLabelPrefix = "label."
 
+// lokiLabelName matches characters not allowed in names of loki labels
+var lokiLabelName = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// getLokiParams renders stream labels in a <label> section: static labels of extra_labels, record fields of
+// label.<name> parameters and pod labels listed in pod_labels
+func (o *Output) getLokiParams() (map[string]string, error) {
+	params, err := o.getParams(lokiFileParams...)
+	if err != nil {
+		return map[string]string{}, err
+	}
 	params["@type"] = "loki"
 
-	var err error
+	labels := map[string]string{}
+	for name, v := range params {
+		if !strings.HasPrefix(name, lokiLabelPrefix) {
+			continue
+		}
+		delete(params, name)
 
-	for _, p := range o.obj.Spec.Params {
-		name := strings.ToLower(p.Name)
-		v := p.Value
-		if len(v) == 0 {
-			if v, err = o.getValueFrom(&p.ValueFrom); err != nil {
-				return map[string]string{}, err
-			}
+		label := name[len(lokiLabelPrefix):]
+		if label == "" || lokiLabelName.MatchString(label) {
+			return map[string]string{}, fmt.Errorf("Loki parameter %s is not a valid label name", name)
 		}
+		if !strings.HasPrefix(v, "$") {
+			v = "$." + v
+		}
+		labels[label] = v
+	}
 
-		params[name] = v
+	// Pod labels are allowed by key, e.g. app.kubernetes.io/name which is label app_kubernetes_io_name. Keys
+	// are accessed in bracket notation, as dot notation of fluentd record accessor splits them on dots and
+	// only allows array indices in brackets.
+	if v, ok := params["pod_labels"]; ok {
+		delete(params, "pod_labels")
+		for _, key := range strings.Split(v, ",") {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			labels[lokiLabelName.ReplaceAllString(key, "_")] = fmt.Sprintf("$['kubernetes']['labels']['%s']", key)
+		}
 	}
 
-	mandatoryParams := []string{"url", "extra_labels"}
+	if _, ok := params["extra_labels"]; !ok && len(labels) == 0 {
+		return map[string]string{}, fmt.Errorf("Mandatory Loki parameter %s is missing", "extra_labels")
+	}
 
-	for _, mp := range mandatoryParams {
-		if _, ok := params[mp]; !ok {
-			return map[string]string{}, fmt.Errorf("Mandatory Loki parameter %s is missing", mp)
-		}
+	if len(labels) > 0 {
+		o.sections = append(o.sections, section{name: "label", params: labels})
 	}
 
 	return params, nil
 }
 
+// validateLokiParams checks line format of loki outputs
+func validateLokiParams(params map[string]string) error {
+	if f, ok := params["line_format"]; ok && f != "json" && f != "key_value" {
+		return fmt.Errorf("Loki parameter line_format %s is not one of json, key_value", f)
+	}
+	return nil
+}
+
 func (o *Output) getS3Params() (map[string]string, error) {
 	var params = make(map[string]string, 1)
 	params["@type"] = "s3"
 
-	for _, p := range o.obj.Spec.Params {
+	for _, p := range o.params() {
 		name := strings.ToLower(p.Name)
 		v := p.Value
 		var err error
@@ -183,30 +309,478 @@ func (o *Output) getS3Params() (map[string]string, error) {
 		params[name] = v
 	}
 
-	mandatoryParams := []string{"s3_bucket", "s3_region"}
+	return params, nil
+}
 
-	for _, mp := range mandatoryParams {
-		if _, ok := params[mp]; !ok {
-			return map[string]string{}, fmt.Errorf("Mandatory S3 parameter %s is missing", mp)
+// defaultObjectPath is the path of objects in object stores other than S3, by namespace and date
+const defaultObjectPath = "logs/${$.kubernetes.namespace_name}/%Y/%m/%d/"
+
+// addObjectBuffer adds buffer of an object store output, whose objects are chunks split by placeholders of
+// path and, if it has time formats, by hour
+func (o *Output) addObjectBuffer(params map[string]string) {
+	keys := chunkKeys(params["path"])
+	if strings.Contains(params["path"], "%") {
+		keys = append(keys, "time")
+		if _, ok := params["timekey"]; !ok {
+			params["timekey"] = "1h"
 		}
 	}
+	o.addBuffer(params, keys)
+}
+
+func (o *Output) getGCSParams() (map[string]string, error) {
+	params, err := o.getParams("keyfile")
+	if err != nil {
+		return map[string]string{}, err
+	}
+	params["@type"] = "gcs"
+
+	// Objects are JSON lines, compressed unless format is json
+	format := "gzip"
+	if f, ok := params["format"]; ok {
+		format = f
+		delete(params, "format")
+	}
+	if format != "json" && format != "gzip" {
+		return map[string]string{}, fmt.Errorf("GCS parameter format %s is not one of json, gzip", format)
+	}
+	params["store_as"] = format
+	o.addFormat(params, "json")
+
+	o.addObjectBuffer(params)
 
 	return params, nil
 }
 
+func (o *Output) getAzureBlobParams() (map[string]string, error) {
+	params, err := o.getParams()
+	if err != nil {
+		return map[string]string{}, err
+	}
+	params["@type"] = "azure-storage-append-blob"
+
+	// Connection string, which may point to an emulator, replaces account and key
+	if _, ok := params["azure_storage_connection_string"]; !ok {
+		mandatoryParams := []string{"azure_storage_account", "azure_storage_access_key"}
+
+		for _, mp := range mandatoryParams {
+			if _, ok := params[mp]; !ok {
+				return map[string]string{}, fmt.Errorf("Mandatory Azure Blob parameter %s is missing", mp)
+			}
+		}
+	}
+
+	// Append blobs can't be compressed
+	if f, ok := params["format"]; ok && f != "json" {
+		return map[string]string{}, fmt.Errorf("Azure Blob parameter format %s is not supported, use json", f)
+	}
+	o.addFormat(params, "json")
+
+	o.addObjectBuffer(params)
+
+	return params, nil
+}
+
+// bufferParams are parameters of <buffer> section, which are moved there from parameters of an output
+var bufferParams = map[string]bool{
+	"chunk_limit_size":    true,
+	"chunk_limit_records": true,
+	"total_limit_size":    true,
+	"flush_mode":          true,
+	"flush_interval":      true,
+	"flush_thread_count":  true,
+	"overflow_action":     true,
+	"retry_forever":       true,
+	"retry_max_interval":  true,
+	"retry_timeout":       true,
+	"timekey":             true,
+	"timekey_wait":        true,
+}
+
+// placeholder matches ${...} placeholders of fluentd, which are resolved from chunk keys of a buffer
+var placeholder = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// chunkKeys returns record fields of placeholders in values, e.g. $.kubernetes.namespace_name of
+// ${$.kubernetes.namespace_name}, so that buffer chunks are split by them
+func chunkKeys(values ...string) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, v := range values {
+		for _, m := range placeholder.FindAllStringSubmatch(v, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				keys = append(keys, m[1])
+			}
+		}
+	}
+	return keys
+}
+
+// addBuffer adds a <buffer> section chunked by keys, moving buffer parameters out of params, if there are any
+func (o *Output) addBuffer(params map[string]string, keys []string) {
+	buffer := section{name: "buffer", arg: strings.Join(keys, ","), params: map[string]string{}}
+	for k, v := range params {
+		if bufferParams[k] {
+			buffer.params[k] = v
+			delete(params, k)
+		}
+	}
+
+	if len(keys) > 0 || len(buffer.params) > 0 {
+		o.sections = append(o.sections, buffer)
+	}
+}
+
+// addFormat adds a <format> section of type given by parameter format, or def if it is not set
+func (o *Output) addFormat(params map[string]string, def string) {
+	format := def
+	if f, ok := params["format"]; ok {
+		format = f
+		delete(params, "format")
+	}
+	o.sections = append(o.sections, section{name: "format", params: map[string]string{"@type": format}})
+}
+
+// kafkaFileParams are TLS credentials of kafka output, which the plugin reads from files
+var kafkaFileParams = []string{"ssl_ca_cert", "ssl_client_cert", "ssl_client_cert_key"}
+
+func (o *Output) getKafkaParams() (map[string]string, error) {
+	params, err := o.getParams(kafkaFileParams...)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	params["@type"] = "kafka2"
+
+	_, hasTopic := params["topic"]
+	_, hasDefault := params["default_topic"]
+	_, hasKey := params["topic_key"]
+	if !hasTopic && !hasDefault && !hasKey {
+		return map[string]string{}, fmt.Errorf("Mandatory Kafka parameter %s is missing", "default_topic")
+	}
+
+	o.addFormat(params, "json")
+
+	// Topic may be templated by record fields, e.g. ${$.kubernetes.namespace_name}, which must be chunk keys
+	o.addBuffer(params, chunkKeys(params["topic"]))
+
+	return params, nil
+}
+
+// splunkFileParams are TLS credentials of splunk output, which the plugin reads from files
+var splunkFileParams = []string{"ca_file", "client_cert", "client_key"}
+
+// splunkFields map parameters naming fields of Kubernetes metadata, e.g. namespace_name or labels.app, to
+// parameters of splunk_hec taking a record field
+var splunkFields = map[string]string{
+	"index_field":      "index_key",
+	"source_field":     "source_key",
+	"sourcetype_field": "sourcetype_key",
+	"host_field":       "host_key",
+}
+
+func (o *Output) getSplunkParams() (map[string]string, error) {
+	params, err := o.getParams(splunkFileParams...)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	params["@type"] = "splunk_hec"
+
+	for field, key := range splunkFields {
+		if v, ok := params[field]; ok {
+			params[key] = "$.kubernetes." + v
+			delete(params, field)
+		}
+	}
+
+	_, hasURL := params["full_url"]
+	_, hasHost := params["hec_host"]
+	if !hasURL && !hasHost {
+		return map[string]string{}, fmt.Errorf("Mandatory Splunk parameter %s is missing", "hec_host")
+	}
+
+	// Each buffer chunk is sent as one batch of events
+	o.addBuffer(params, nil)
+
+	return params, nil
+}
+
+// httpFileParams are TLS credentials of http output, which the plugin reads from files
+var httpFileParams = []string{"tls_ca_cert_path", "tls_client_cert_path", "tls_private_key_path"}
+
+// headerPrefix prefixes parameters which set a header of http output, e.g. header.Authorization
+const headerPrefix = "header."
+
+func (o *Output) getHTTPParams() (map[string]string, error) {
+	params, err := o.getParams(httpFileParams...)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	params["@type"] = "http"
+	if m, ok := params["http_method"]; ok {
+		params["http_method"] = strings.ToLower(m)
+	}
+
+	// Headers keep case of their names, and values of secrets are passed as is
+	headers := map[string]string{}
+	for _, p := range o.params() {
+		if !strings.HasPrefix(strings.ToLower(p.Name), headerPrefix) {
+			continue
+		}
+		v, err := o.getRaw(&p)
+		if err != nil {
+			return map[string]string{}, err
+		}
+		headers[p.Name[len(headerPrefix):]] = string(v)
+		delete(params, strings.ToLower(p.Name))
+	}
+	if len(headers) > 0 {
+		h, err := json.Marshal(headers)
+		if err != nil {
+			return map[string]string{}, err
+		}
+		params["headers"] = string(h)
+	}
+
+	// Records are sent as newline delimited JSON, or as a JSON array with format json_array
+	switch params["format"] {
+	case "json_array":
+		params["json_array"] = "true"
+		params["format"] = "json"
+	case "", "ndjson":
+		params["format"] = "json"
+	}
+	o.addFormat(params, "json")
+
+	o.addBuffer(params, chunkKeys(params["endpoint"]))
+
+	return params, nil
+}
+
+// validateHTTPParams checks endpoint, method and retried status codes of http outputs
+func validateHTTPParams(params map[string]string) error {
+	endpoint := params["endpoint"]
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("HTTP parameter endpoint %s is not an http(s) URL", endpoint)
+	}
+
+	if m, ok := params["http_method"]; ok && strings.ToLower(m) != "post" && strings.ToLower(m) != "put" {
+		return fmt.Errorf("HTTP parameter http_method %s is not one of post, put", m)
+	}
+
+	if codes, ok := params["retryable_response_codes"]; ok {
+		for _, c := range strings.Split(codes, ",") {
+			if code, err := strconv.Atoi(strings.TrimSpace(c)); err != nil || code < 100 || code > 599 {
+				return fmt.Errorf("HTTP parameter retryable_response_codes %s is not a list of status codes", codes)
+			}
+		}
+	}
+	return nil
+}
+
+// syslogAppNames map values of parameter app_name of syslog output to fields of Kubernetes metadata
+var syslogAppNames = map[string]string{
+	"container": "container_name",
+	"pod":       "pod_name",
+}
+
+// getSyslogParams renders remote_syslog for RFC3164 framing, and syslog_rfc5424 for RFC5424 framing, which
+// remote_syslog doesn't support
+func (o *Output) getSyslogParams() (map[string]string, error) {
+	params, err := o.getParams("ca_file")
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	protocol := "udp"
+	if p, ok := params["protocol"]; ok {
+		protocol = p
+		delete(params, "protocol")
+	}
+	if protocol != "udp" && protocol != "tcp" && protocol != "tls" {
+		return map[string]string{}, fmt.Errorf("Syslog parameter protocol %s is not one of udp, tcp, tls", protocol)
+	}
+
+	appName := "container"
+	if a, ok := params["app_name"]; ok {
+		appName = a
+		delete(params, "app_name")
+	}
+	appField, ok := syslogAppNames[appName]
+	if !ok {
+		return map[string]string{}, fmt.Errorf("Syslog parameter app_name %s is not one of container, pod", appName)
+	}
+
+	rfc := "rfc3164"
+	if r, ok := params["rfc"]; ok {
+		rfc = r
+		delete(params, "rfc")
+	}
+
+	switch rfc {
+	case "rfc3164":
+		params["@type"] = "remote_syslog"
+		params["protocol"] = protocol
+		if protocol == "tls" {
+			params["protocol"] = "tcp"
+			params["tls"] = "true"
+		}
+		params["program"] = fmt.Sprintf("${$.kubernetes.%s}", appField)
+
+		// Facility and severity may be taken from record fields
+		for _, p := range []string{"facility", "severity"} {
+			if f, ok := params[p+"_field"]; ok {
+				params[p] = fmt.Sprintf("${%s}", f)
+				delete(params, p+"_field")
+			}
+		}
+
+		o.sections = append(o.sections, section{name: "format",
+			params: map[string]string{"@type": "single_value", "message_key": "log"}})
+		o.addBuffer(params, chunkKeys(params["program"], params["facility"], params["severity"]))
+	case "rfc5424":
+		for _, p := range []string{"facility", "severity", "facility_field", "severity_field"} {
+			if _, ok := params[p]; ok {
+				return map[string]string{}, fmt.Errorf("Syslog parameter %s is not supported with rfc5424", p)
+			}
+		}
+
+		params["@type"] = "syslog_rfc5424"
+		params["transport"] = protocol
+		if ca, ok := params["ca_file"]; ok {
+			params["trusted_ca_path"] = ca
+			delete(params, "ca_file")
+		}
+
+		o.sections = append(o.sections, section{name: "format",
+			params: map[string]string{"@type": "syslog_rfc5424", "app_name_field": "kubernetes." + appField}})
+		o.addBuffer(params, nil)
+	default:
+		return map[string]string{}, fmt.Errorf("Syslog parameter rfc %s is not one of rfc3164, rfc5424", rfc)
+	}
+
+	return params, nil
+}
+
+// forwardFileParams are TLS credentials of forward output, which the plugin reads from files
+var forwardFileParams = []string{"tls_cert_path", "tls_client_cert_path", "tls_client_private_key_path"}
+
+// getForwardParams renders a <server> section for every server parameter, whose value is host[:port]
+// followed by options weight=<weight>, standby and name=<name>, e.g. "aggregator-0:24224 weight=60"
+func (o *Output) getForwardParams() (map[string]string, error) {
+	params, err := o.getParams(forwardFileParams...)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	params["@type"] = "forward"
+	delete(params, "server")
+
+	servers := 0
+	for _, p := range o.params() {
+		if strings.ToLower(p.Name) != "server" {
+			continue
+		}
+		v, err := o.getRaw(&p)
+		if err != nil {
+			return map[string]string{}, err
+		}
+
+		server, err := parseServer(string(v))
+		if err != nil {
+			return map[string]string{}, err
+		}
+		o.sections = append(o.sections, server)
+		servers++
+	}
+
+	if servers == 0 {
+		return map[string]string{}, fmt.Errorf("Mandatory Forward parameter %s is missing", "server")
+	}
+
+	if _, ok := params["tls_cert_path"]; ok {
+		params["transport"] = "tls"
+	}
+
+	// Shared key authenticates with servers, which see fluentd pods by their hostname
+	if key, ok := params["shared_key"]; ok {
+		o.sections = append(o.sections, section{name: "security", params: map[string]string{
+			"self_hostname": "\"#{Socket.gethostname}\"",
+			"shared_key":    key,
+		}})
+		delete(params, "shared_key")
+	}
+
+	o.addBuffer(params, nil)
+
+	return params, nil
+}
+
+// parseServer returns <server> section of forward output from value of a server parameter
+func parseServer(v string) (section, error) {
+	fields := strings.Fields(v)
+	if len(fields) == 0 {
+		return section{}, fmt.Errorf("Forward parameter server is empty")
+	}
+
+	server := section{name: "server", params: map[string]string{}}
+	hostPort := strings.SplitN(fields[0], ":", 2)
+	server.params["host"] = hostPort[0]
+	if len(hostPort) > 1 {
+		if _, err := strconv.Atoi(hostPort[1]); err != nil {
+			return section{}, fmt.Errorf("Forward server %s has invalid port", v)
+		}
+		server.params["port"] = hostPort[1]
+	}
+
+	for _, opt := range fields[1:] {
+		kv := strings.SplitN(opt, "=", 2)
+		switch {
+		case kv[0] == "standby" && len(kv) == 1:
+			server.params["standby"] = "true"
+		case kv[0] == "weight" && len(kv) == 2:
+			if _, err := strconv.Atoi(kv[1]); err != nil {
+				return section{}, fmt.Errorf("Forward server %s has invalid weight", v)
+			}
+			server.params["weight"] = kv[1]
+		case kv[0] == "name" && len(kv) == 2:
+			server.params["name"] = kv[1]
+		default:
+			return section{}, fmt.Errorf("Forward server %s has unknown option %s", v, opt)
+		}
+	}
+
+	return server, nil
+}
+
 func (o *Output) getValueFrom(vf *v1alpha1.ValueFrom) (string, error) {
+	v, err := o.getSecretData(vf)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("\"%s\"", string(v)), nil
+}
+
+// getRaw returns value of a parameter as is, without quoting values of secrets
+func (o *Output) getRaw(p *v1alpha1.Param) ([]byte, error) {
+	if len(p.Value) > 0 {
+		return []byte(p.Value), nil
+	}
+	return o.getSecretData(&p.ValueFrom)
+}
+
+// getSecretData returns the value a secret reference points to, as is
+func (o *Output) getSecretData(vf *v1alpha1.ValueFrom) ([]byte, error) {
 	secret := corev1.Secret{}
 	secretName := types.NamespacedName{Name: vf.Name, Namespace: vf.Namespace}
 
 	if err := o.client.Get(context.TODO(), secretName, &secret); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	for k, v := range secret.Data {
 		if k == vf.Key {
-			return fmt.Sprintf("\"%s\"", string(v)), nil
+			return v, nil
 		}
 	}
 
-	return "", fmt.Errorf("Key %s was not found in secret %s", vf.Key, vf.Name)
+	return nil, fmt.Errorf("Key %s was not found in secret %s", vf.Key, vf.Name)
 }
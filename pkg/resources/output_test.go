@@ -212,6 +212,112 @@ func TestLokiRender(t *testing.T) {
 
 }
 
+func TestLokiLabels(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "loki",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "url",
+					Value: "fake-url",
+				},
+				v1alpha1.Param{
+					Name:  "label.namespace",
+					Value: "kubernetes.namespace_name",
+				},
+				v1alpha1.Param{
+					Name:  "label.container",
+					Value: "$.kubernetes.container_name",
+				},
+				v1alpha1.Param{
+					Name:  "pod_labels",
+					Value: "app, app.kubernetes.io/name",
+				},
+				v1alpha1.Param{
+					Name: "tenant",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "tenant",
+					},
+				},
+				v1alpha1.Param{
+					Name: "bearer_token_file",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "token",
+					},
+				},
+				v1alpha1.Param{
+					Name:  "remove_keys",
+					Value: "kubernetes",
+				},
+				v1alpha1.Param{
+					Name:  "line_format",
+					Value: "key_value",
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake",
+		},
+		Data: map[string][]byte{
+			"tenant": []byte("fake-tenant"),
+			"token":  []byte("fake-token"),
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(&secret), &obj)
+
+	assert.NotNil(t, o)
+
+	params, err := o.getLokiParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"url", "tenant", "bearer_token_file", "remove_keys", "line_format"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, "\"fake-tenant\"", params["tenant"])
+	assert.Equal(t, "/fluentd/etc/fake.bearer_token_file", params["bearer_token_file"])
+	assert.NotContains(t, params, "pod_labels")
+	assert.Equal(t, []section{section{name: "label", params: map[string]string{
+		"namespace":              "$.kubernetes.namespace_name",
+		"container":              "$.kubernetes.container_name",
+		"app":                    "$['kubernetes']['labels']['app']",
+		"app_kubernetes_io_name": "$['kubernetes']['labels']['app.kubernetes.io/name']",
+	}}}, o.sections)
+
+	// Labels must be valid and line format json or key_value
+	for _, p := range []v1alpha1.Param{
+		v1alpha1.Param{Name: "label.pod-name", Value: "kubernetes.pod_name"},
+		v1alpha1.Param{Name: "line_format", Value: "logfmt"},
+	} {
+		obj.Spec.Params = []v1alpha1.Param{v1alpha1.Param{Name: "url", Value: "fake-url"},
+			v1alpha1.Param{Name: "extra_labels", Value: "fake-labels"}, p}
+		_, err = o.renderParams()
+		assert.NotNil(t, err)
+	}
+
+	// Some label is mandatory
+	obj.Spec.Params = []v1alpha1.Param{v1alpha1.Param{Name: "url", Value: "fake-url"}}
+	_, err = o.getLokiParams()
+	assert.NotNil(t, err)
+}
+
 func TestS3Params(t *testing.T) {
 	obj := v1alpha1.Output{
 		ObjectMeta: metav1.ObjectMeta{
@@ -353,3 +459,1083 @@ func TestS3Render(t *testing.T) {
 	assert.Nil(t, err)
 
 }
+
+func TestKafkaParams(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "kafka",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "brokers",
+					Value: "kafka-0:9093,kafka-1:9093",
+				},
+				v1alpha1.Param{
+					Name:  "topic",
+					Value: "logs.${$.kubernetes.namespace_name}",
+				},
+				v1alpha1.Param{
+					Name:  "scram_mechanism",
+					Value: "sha256",
+				},
+				v1alpha1.Param{
+					Name: "password",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "password",
+					},
+				},
+				v1alpha1.Param{
+					Name: "ssl_ca_cert",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "ca.crt",
+					},
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake",
+		},
+		Data: map[string][]byte{
+			"password": []byte("fake-password"),
+			"ca.crt":   []byte("fake-ca"),
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(&secret), &obj)
+
+	assert.NotNil(t, o)
+
+	params, err := o.getKafkaParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"@type", "brokers", "topic", "scram_mechanism", "password", "ssl_ca_cert"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, "kafka2", params["@type"])
+	assert.Equal(t, "\"fake-password\"", params["password"])
+	assert.Equal(t, "/fluentd/etc/fake.ssl_ca_cert", params["ssl_ca_cert"])
+	assert.Equal(t, "fake-ca", string(o.Files()["fake.ssl_ca_cert"]))
+
+	// Brokers and a topic are mandatory
+	obj.Spec.Params = obj.Spec.Params[1:]
+	_, err = o.renderParams()
+	assert.NotNil(t, err)
+
+	obj.Spec.Params = []v1alpha1.Param{v1alpha1.Param{Name: "brokers", Value: "kafka-0:9093"}}
+	_, err = o.getKafkaParams()
+	assert.NotNil(t, err)
+}
+
+func TestKafkaRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "kafka",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "brokers",
+					Value: "kafka-0:9093",
+				},
+				v1alpha1.Param{
+					Name:  "topic",
+					Value: "logs.${$.kubernetes.namespace_name}",
+				},
+				v1alpha1.Param{
+					Name:  "compression_codec",
+					Value: "gzip",
+				},
+				v1alpha1.Param{
+					Name:  "required_acks",
+					Value: "-1",
+				},
+			},
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "@type kafka2")
+	assert.Contains(t, conf, "compression_codec gzip")
+	assert.Contains(t, conf, "<format>\n            @type json\n        </format>")
+	assert.Contains(t, conf, "<buffer $.kubernetes.namespace_name>")
+}
+
+func TestSplunkParams(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "splunk",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "full_url",
+					Value: "https://splunk:8088/services/collector",
+				},
+				v1alpha1.Param{
+					Name: "hec_token",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "hec_token",
+					},
+				},
+				v1alpha1.Param{
+					Name:  "index_field",
+					Value: "namespace_name",
+				},
+				v1alpha1.Param{
+					Name: "ca_file",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "ca.crt",
+					},
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake",
+		},
+		Data: map[string][]byte{
+			"hec_token": []byte("fake-token"),
+			"ca.crt":    []byte("fake-ca"),
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(&secret), &obj)
+
+	assert.NotNil(t, o)
+
+	params, err := o.getSplunkParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"@type", "full_url", "hec_token", "index_key", "ca_file"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, "splunk_hec", params["@type"])
+	assert.Equal(t, "$.kubernetes.namespace_name", params["index_key"])
+	assert.Equal(t, "/fluentd/etc/fake.ca_file", params["ca_file"])
+
+	// Token is mandatory
+	obj.Spec.Params = []v1alpha1.Param{v1alpha1.Param{Name: "hec_host", Value: "splunk"}}
+	_, err = o.renderParams()
+	assert.NotNil(t, err)
+}
+
+func TestSplunkRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "splunk",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "hec_host",
+					Value: "splunk",
+				},
+				v1alpha1.Param{
+					Name:  "hec_token",
+					Value: "fake-token",
+				},
+				v1alpha1.Param{
+					Name:  "insecure_ssl",
+					Value: "true",
+				},
+				v1alpha1.Param{
+					Name:  "flush_interval",
+					Value: "5s",
+				},
+			},
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "@type splunk_hec")
+	assert.Contains(t, conf, "insecure_ssl true")
+	assert.Contains(t, conf, "<buffer>\n            flush_interval 5s\n        </buffer>")
+}
+
+func TestHTTPParams(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "http",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "endpoint",
+					Value: "https://logs.example.com/ingest",
+				},
+				v1alpha1.Param{
+					Name: "header.Authorization",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "token",
+					},
+				},
+				v1alpha1.Param{
+					Name:  "format",
+					Value: "json_array",
+				},
+				v1alpha1.Param{
+					Name:  "retryable_response_codes",
+					Value: "503,504",
+				},
+				v1alpha1.Param{
+					Name: "tls_client_cert_path",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "tls.crt",
+					},
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake",
+		},
+		Data: map[string][]byte{
+			"token":   []byte("Bearer fake-token"),
+			"tls.crt": []byte("fake-cert"),
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(&secret), &obj)
+
+	assert.NotNil(t, o)
+
+	params, err := o.getHTTPParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"@type", "endpoint", "headers", "json_array", "retryable_response_codes", "tls_client_cert_path"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, `{"Authorization":"Bearer fake-token"}`, params["headers"])
+	assert.Equal(t, "/fluentd/etc/fake.tls_client_cert_path", params["tls_client_cert_path"])
+	assert.NotContains(t, params, "header.authorization")
+	assert.NotContains(t, params, "format")
+
+	// Invalid parameters fail to render
+	for _, p := range []v1alpha1.Param{
+		v1alpha1.Param{Name: "endpoint", Value: "logs.example.com"},
+		v1alpha1.Param{Name: "http_method", Value: "get"},
+		v1alpha1.Param{Name: "retryable_response_codes", Value: "503,unavailable"},
+	} {
+		obj.Spec.Params = []v1alpha1.Param{v1alpha1.Param{Name: "endpoint", Value: "https://logs.example.com"}, p}
+		_, err = o.renderParams()
+		assert.NotNil(t, err)
+	}
+
+	obj.Spec.Params = nil
+	_, err = o.renderParams()
+	assert.NotNil(t, err)
+}
+
+func TestHTTPRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "http",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "endpoint",
+					Value: "http://logs.example.com/${$.kubernetes.namespace_name}",
+				},
+				v1alpha1.Param{
+					Name:  "http_method",
+					Value: "put",
+				},
+			},
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "@type http")
+	assert.Contains(t, conf, "http_method put")
+	assert.Contains(t, conf, "<format>\n            @type json\n        </format>")
+	assert.Contains(t, conf, "<buffer $.kubernetes.namespace_name>")
+
+	// Methods are case insensitive
+	obj.Spec.Params[1].Value = "POST"
+	out, err = o.Render()
+	assert.Nil(t, err)
+	assert.Contains(t, string(out), "http_method post")
+}
+
+func TestSyslogParams(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "syslog",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "host",
+					Value: "siem.example.com",
+				},
+				v1alpha1.Param{
+					Name:  "protocol",
+					Value: "tls",
+				},
+				v1alpha1.Param{
+					Name:  "severity_field",
+					Value: "level",
+				},
+				v1alpha1.Param{
+					Name:  "app_name",
+					Value: "pod",
+				},
+				v1alpha1.Param{
+					Name: "ca_file",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "ca.crt",
+					},
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake",
+		},
+		Data: map[string][]byte{
+			"ca.crt": []byte("fake-ca"),
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(&secret), &obj)
+
+	assert.NotNil(t, o)
+
+	params, err := o.renderParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"@type", "host", "port", "protocol", "tls", "severity", "program", "ca_file"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, "remote_syslog", params["@type"])
+	assert.Equal(t, "tcp", params["protocol"])
+	assert.Equal(t, "6514", params["port"])
+	assert.Equal(t, "${level}", params["severity"])
+	assert.Equal(t, "${$.kubernetes.pod_name}", params["program"])
+
+	// RFC5424 framing is rendered with syslog_rfc5424
+	obj.Spec.Params = []v1alpha1.Param{
+		v1alpha1.Param{Name: "host", Value: "siem.example.com"},
+		v1alpha1.Param{Name: "rfc", Value: "rfc5424"},
+		v1alpha1.Param{Name: "protocol", Value: "tcp"},
+	}
+	params, err = o.getSyslogParams()
+	assert.Nil(t, err)
+	assert.Equal(t, "syslog_rfc5424", params["@type"])
+	assert.Equal(t, "tcp", params["transport"])
+
+	// Invalid parameters fail to render
+	for _, p := range []v1alpha1.Param{
+		v1alpha1.Param{Name: "protocol", Value: "http"},
+		v1alpha1.Param{Name: "rfc", Value: "rfc0"},
+		v1alpha1.Param{Name: "app_name", Value: "node"},
+	} {
+		obj.Spec.Params = []v1alpha1.Param{v1alpha1.Param{Name: "host", Value: "siem.example.com"}, p}
+		_, err = o.getSyslogParams()
+		assert.NotNil(t, err)
+	}
+
+	obj.Spec.Params = nil
+	_, err = o.renderParams()
+	assert.NotNil(t, err)
+}
+
+func TestSyslogRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "syslog",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "host",
+					Value: "siem.example.com",
+				},
+				v1alpha1.Param{
+					Name:  "facility",
+					Value: "local0",
+				},
+			},
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "@type remote_syslog")
+	assert.Contains(t, conf, "port 514")
+	assert.Contains(t, conf, "protocol udp")
+	assert.Contains(t, conf, "facility local0")
+	assert.Contains(t, conf, "<buffer $.kubernetes.container_name>")
+}
+
+func TestForwardParams(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "forward",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "server",
+					Value: "aggregator-0:24224 weight=60 name=primary",
+				},
+				v1alpha1.Param{
+					Name:  "server",
+					Value: "aggregator-1 standby",
+				},
+				v1alpha1.Param{
+					Name: "shared_key",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "shared_key",
+					},
+				},
+				v1alpha1.Param{
+					Name: "tls_cert_path",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "ca.crt",
+					},
+				},
+				v1alpha1.Param{
+					Name:  "require_ack_response",
+					Value: "true",
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake",
+		},
+		Data: map[string][]byte{
+			"shared_key": []byte("fake-key"),
+			"ca.crt":     []byte("fake-ca"),
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(&secret), &obj)
+
+	assert.NotNil(t, o)
+
+	params, err := o.getForwardParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"@type", "transport", "tls_cert_path", "require_ack_response"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.NotContains(t, params, "server")
+	assert.NotContains(t, params, "shared_key")
+	assert.Equal(t, "tls", params["transport"])
+	assert.Equal(t, []section{
+		section{name: "server", params: map[string]string{"host": "aggregator-0", "port": "24224", "weight": "60", "name": "primary"}},
+		section{name: "server", params: map[string]string{"host": "aggregator-1", "standby": "true"}},
+		section{name: "security", params: map[string]string{"self_hostname": "\"#{Socket.gethostname}\"", "shared_key": "\"fake-key\""}},
+	}, o.sections)
+
+	// Servers are mandatory and must be valid
+	for _, params := range [][]v1alpha1.Param{
+		nil,
+		[]v1alpha1.Param{v1alpha1.Param{Name: "server", Value: "aggregator-0:forward"}},
+		[]v1alpha1.Param{v1alpha1.Param{Name: "server", Value: "aggregator-0 weight=heavy"}},
+		[]v1alpha1.Param{v1alpha1.Param{Name: "server", Value: "aggregator-0 primary"}},
+	} {
+		obj.Spec.Params = params
+		_, err = o.getForwardParams()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestForwardRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "forward",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "server",
+					Value: "aggregator-0:24224",
+				},
+				v1alpha1.Param{
+					Name:  "flush_interval",
+					Value: "1s",
+				},
+			},
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "@type forward")
+	assert.Contains(t, conf, "<server>\n            host aggregator-0\n            port 24224\n        </server>")
+	assert.Contains(t, conf, "<buffer>\n            flush_interval 1s\n        </buffer>")
+}
+
+func TestGCSParams(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "gcs",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "bucket",
+					Value: "fake-bucket",
+				},
+				v1alpha1.Param{
+					Name:  "project",
+					Value: "fake-project",
+				},
+				v1alpha1.Param{
+					Name: "keyfile",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "key.json",
+					},
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake",
+		},
+		Data: map[string][]byte{
+			"key.json": []byte("{}"),
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(&secret), &obj)
+
+	assert.NotNil(t, o)
+
+	params, err := o.renderParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"@type", "bucket", "project", "keyfile", "path", "store_as"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, "/fluentd/etc/fake.keyfile", params["keyfile"])
+	assert.Equal(t, "gzip", params["store_as"])
+	assert.Equal(t, "{}", string(o.Files()["fake.keyfile"]))
+
+	// Bucket is mandatory and format is json or gzip
+	obj.Spec.Params = []v1alpha1.Param{v1alpha1.Param{Name: "project", Value: "fake-project"}}
+	_, err = o.renderParams()
+	assert.NotNil(t, err)
+
+	obj.Spec.Params = []v1alpha1.Param{v1alpha1.Param{Name: "bucket", Value: "fake-bucket"}, v1alpha1.Param{Name: "format", Value: "csv"}}
+	_, err = o.getGCSParams()
+	assert.NotNil(t, err)
+}
+
+func TestGCSRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "gcs",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "bucket",
+					Value: "fake-bucket",
+				},
+				v1alpha1.Param{
+					Name:  "format",
+					Value: "json",
+				},
+			},
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "@type gcs")
+	assert.Contains(t, conf, "store_as json")
+	assert.Contains(t, conf, "path logs/${$.kubernetes.namespace_name}/%Y/%m/%d/")
+	assert.Contains(t, conf, "<buffer $.kubernetes.namespace_name,time>\n            timekey 1h\n        </buffer>")
+}
+
+func TestAzureBlobParams(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "azureblob",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "azure_container",
+					Value: "fake-container",
+				},
+				v1alpha1.Param{
+					Name: "azure_storage_connection_string",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "connection_string",
+					},
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake",
+		},
+		Data: map[string][]byte{
+			"connection_string": []byte("UseDevelopmentStorage=true"),
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(&secret), &obj)
+
+	assert.NotNil(t, o)
+
+	params, err := o.renderParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"@type", "azure_container", "azure_storage_connection_string", "path"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, "azure-storage-append-blob", params["@type"])
+
+	// Account and key are mandatory without connection string
+	obj.Spec.Params = []v1alpha1.Param{
+		v1alpha1.Param{Name: "azure_container", Value: "fake-container"},
+		v1alpha1.Param{Name: "azure_storage_account", Value: "fake-account"},
+	}
+	_, err = o.getAzureBlobParams()
+	assert.NotNil(t, err)
+
+	obj.Spec.Params = append(obj.Spec.Params, v1alpha1.Param{Name: "azure_storage_access_key", Value: "fake-key"})
+	_, err = o.getAzureBlobParams()
+	assert.Nil(t, err)
+
+	obj.Spec.Params = append(obj.Spec.Params, v1alpha1.Param{Name: "format", Value: "gzip"})
+	_, err = o.getAzureBlobParams()
+	assert.NotNil(t, err)
+}
+
+func TestAzureBlobRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "azureblob",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "azure_container",
+					Value: "fake-container",
+				},
+				v1alpha1.Param{
+					Name:  "azure_storage_connection_string",
+					Value: "UseDevelopmentStorage=true",
+				},
+				v1alpha1.Param{
+					Name:  "path",
+					Value: "${$.kubernetes.namespace_name}/",
+				},
+			},
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "@type azure-storage-append-blob")
+	assert.Contains(t, conf, "<buffer $.kubernetes.namespace_name>")
+}
+
+func TestSearchParams(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "elasticsearch",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "logstash_format",
+					Value: "true",
+				},
+				v1alpha1.Param{
+					Name:  "index_template",
+					Value: `{"index_patterns": ["fluentd-fake-*"]}`,
+				},
+				v1alpha1.Param{
+					Name:  "ilm_policy",
+					Value: `{"policy": {"phases": {}}}`,
+				},
+			},
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	// Template and policy are installed by fluentd
+	params, err := o.getEsParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"logstash_prefix", "template_name", "template_file", "enable_ilm", "ilm_policy_id", "ilm_policy"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, "fluentd-fake", params["logstash_prefix"])
+	assert.Equal(t, `{"policy":{"phases":{}}}`, params["ilm_policy"])
+	assert.Equal(t, `{"index_patterns": ["fluentd-fake-*"]}`, string(o.Files()["fake.index_template"]))
+	assert.Nil(t, o.SearchSetup())
+
+	// Operator installs them in data streams of elasticsearch
+	obj.Spec.Params = append(obj.Spec.Params[1:],
+		v1alpha1.Param{Name: "url", Value: "https://es:9243"},
+		v1alpha1.Param{Name: "data_stream_name", Value: "logs-fake"},
+		v1alpha1.Param{Name: "install_by", Value: "operator"},
+		v1alpha1.Param{Name: "user", Value: "elastic"})
+	o = NewOutput(fake.NewFakeClient(), &obj)
+	params, err = o.getEsParams()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "elasticsearch_data_stream", params["@type"])
+	assert.Equal(t, "fluentd-fake", params["data_stream_template_name"])
+	assert.Equal(t, "fluentd-fake", params["data_stream_ilm_name"])
+	assert.NotContains(t, params, "data_stream_ilm_policy")
+	assert.NotContains(t, params, "index_name")
+	assert.Empty(t, o.Files())
+	assert.Equal(t, &SearchSetup{
+		URL:  "https://es:9243",
+		User: "elastic",
+		Resources: []SearchResource{
+			SearchResource{Path: "/_ilm/policy/fluentd-fake", Body: []byte(`{"policy": {"phases": {}}}`)},
+			SearchResource{Path: "/_index_template/fluentd-fake", Body: []byte(`{"index_patterns": ["fluentd-fake-*"]}`)},
+		},
+	}, o.SearchSetup())
+
+	// Invalid combinations fail to render
+	for _, params := range [][]v1alpha1.Param{
+		[]v1alpha1.Param{v1alpha1.Param{Name: "ism_policy", Value: "{}"}},
+		[]v1alpha1.Param{v1alpha1.Param{Name: "index_template", Value: "{"}},
+		[]v1alpha1.Param{v1alpha1.Param{Name: "install_by", Value: "helm"}},
+		[]v1alpha1.Param{v1alpha1.Param{Name: "data_stream_name", Value: "logs"}, v1alpha1.Param{Name: "logstash_format", Value: "true"}},
+		[]v1alpha1.Param{v1alpha1.Param{Name: "data_stream_name", Value: "logs"}, v1alpha1.Param{Name: "index_template", Value: "{}"}},
+	} {
+		obj.Spec.Params = params
+		_, err = o.getEsParams()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestOpenSearchParams(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "opensearch",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "ism_policy",
+					Value: `{"policy": {}}`,
+				},
+				v1alpha1.Param{
+					Name:  "install_by",
+					Value: "operator",
+				},
+				v1alpha1.Param{
+					Name: "password",
+					ValueFrom: v1alpha1.ValueFrom{
+						Name:      "fake-secret",
+						Namespace: "fake",
+						Key:       "password",
+					},
+				},
+			},
+		},
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake",
+		},
+		Data: map[string][]byte{
+			"password": []byte("fake-password"),
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(&secret), &obj)
+
+	assert.NotNil(t, o)
+
+	params, err := o.getOpenSearchParams()
+
+	assert.Nil(t, err)
+
+	keys := []string{"@type", "index_name", "host", "port", "scheme", "password"}
+
+	for _, k := range keys {
+		_, ok := params[k]
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, "opensearch", params["@type"])
+	assert.Equal(t, "opensearch", params["host"])
+	assert.NotContains(t, params, "enable_ilm")
+	assert.Equal(t, "fake-password", o.SearchSetup().Password)
+	assert.Equal(t, "/_plugins/_ism/policies/fluentd-fake", o.SearchSetup().Resources[0].Path)
+
+	// fluentd doesn't install ISM policies
+	obj.Spec.Params = obj.Spec.Params[:1]
+	_, err = o.getOpenSearchParams()
+	assert.NotNil(t, err)
+}
+
+func TestOpenSearchRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "opensearch",
+			Params: []v1alpha1.Param{
+				v1alpha1.Param{
+					Name:  "url",
+					Value: "https://opensearch:9200",
+				},
+				v1alpha1.Param{
+					Name:  "data_stream_name",
+					Value: "logs-fake",
+				},
+			},
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "@type opensearch_data_stream")
+	assert.Contains(t, conf, "data_stream_name logs-fake")
+}
+
+func TestStdoutRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type:      "stdout",
+			Namespace: "team-a",
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "<match kube.** node.** k8s.events.**>\n        @type stdout\n    </match>")
+	assert.Contains(t, conf, "<regexp>\n            key $.kubernetes.namespace_name\n            pattern /^team-a$/\n        </regexp>")
+	assert.Contains(t, conf, "<exclude>\n            key $.kubernetes.labels.k8s-app\n            pattern /^fluentd$/\n        </exclude>")
+}
+
+func TestFileRender(t *testing.T) {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "fake",
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "file",
+		},
+	}
+
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	assert.NotNil(t, o)
+
+	out, err := o.Render()
+
+	assert.Nil(t, err)
+
+	conf := string(out)
+	assert.Contains(t, conf, "@type file")
+	assert.Contains(t, conf, "path /fluentd/log/fake")
+	assert.NotContains(t, conf, "<filter")
+
+	// Types are validated
+	obj.Spec.Type = "ender"
+	_, err = o.Render()
+	assert.NotNil(t, err)
+}
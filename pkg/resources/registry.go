@@ -0,0 +1,216 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+)
+
+// Renderer renders outputs of a type into parameters of a fluentd output plugin. Output.Render sets defaults
+// missing from the output, checks required parameters, then renders and validates parameters.
+type Renderer interface {
+	// Required returns names of parameters the output must set
+	Required() []string
+	// Defaults returns values of parameters which the output doesn't set, by name
+	Defaults(o *Output) map[string]string
+	// Render returns parameters of the plugin, including @type. Nested sections and files may be added to o.
+	Render(o *Output) (map[string]string, error)
+	// Validate checks rendered parameters
+	Validate(params map[string]string) error
+}
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]Renderer{}
+)
+
+// Register makes outputs of type typ rendered by r. It panics if typ is registered twice, like
+// registration of types in init functions is expected to happen once.
+func Register(typ string, r Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+
+	typ = strings.ToLower(typ)
+	if _, ok := renderers[typ]; ok {
+		panic(fmt.Sprintf("output type %s is already registered", typ))
+	}
+	renderers[typ] = r
+}
+
+// Types returns registered output types, sorted
+func Types() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	types := []string{}
+	for typ := range renderers {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func getRenderer(typ string) (Renderer, bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	r, ok := renderers[strings.ToLower(typ)]
+	return r, ok
+}
+
+// renderParams returns parameters of the output plugin, rendered by the renderer of the output type
+func (o *Output) renderParams() (map[string]string, error) {
+	r, ok := getRenderer(o.obj.Spec.Type)
+	if !ok {
+		return map[string]string{}, fmt.Errorf("Invalid type: %s, supported types are %s", o.obj.Spec.Type,
+			strings.Join(Types(), ", "))
+	}
+
+	o.sections = nil
+	o.files = map[string][]byte{}
+	o.setup = nil
+
+	// Defaults are rendered like parameters of the output
+	o.defaults = nil
+	for name, v := range r.Defaults(o) {
+		if !o.hasParam(name) {
+			o.defaults = append(o.defaults, v1alpha1.Param{Name: name, Value: v})
+		}
+	}
+	sort.Slice(o.defaults, func(i, j int) bool { return o.defaults[i].Name < o.defaults[j].Name })
+
+	for _, mp := range r.Required() {
+		if !o.hasParam(mp) {
+			return map[string]string{}, fmt.Errorf("Mandatory %s parameter %s is missing", o.obj.Spec.Type, mp)
+		}
+	}
+
+	params, err := r.Render(o)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	if err := r.Validate(params); err != nil {
+		return map[string]string{}, err
+	}
+	return params, nil
+}
+
+// hasParam returns whether the output sets parameter name
+func (o *Output) hasParam(name string) bool {
+	for _, p := range o.params() {
+		if strings.ToLower(p.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// params returns parameters of the output spec, followed by defaults of its type which it doesn't set
+func (o *Output) params() []v1alpha1.Param {
+	params := append([]v1alpha1.Param{}, o.obj.Spec.Params...)
+	return append(params, o.defaults...)
+}
+
+// Params returns parameters of the output, with values of references to secrets resolved and quoted.
+// Parameters named in fileParams are stored in files of fluentd configuration directory, which they refer to
+// by path, such as TLS certificates.
+func (o *Output) Params(fileParams ...string) (map[string]string, error) {
+	return o.getParams(fileParams...)
+}
+
+// AddSection adds a nested directive to the output, e.g. <buffer tag> where name is "buffer" and arg is "tag"
+func (o *Output) AddSection(name, arg string, params map[string]string) {
+	o.sections = append(o.sections, section{name: name, arg: arg, params: params})
+}
+
+// builtin is the renderer of output types of the operator, whose parameters are rendered by methods of Output
+type builtin struct {
+	required []string
+	defaults func(o *Output) map[string]string
+	render   func(o *Output) (map[string]string, error)
+	validate func(params map[string]string) error
+}
+
+func (b *builtin) Required() []string {
+	return b.required
+}
+
+func (b *builtin) Defaults(o *Output) map[string]string {
+	if b.defaults == nil {
+		return nil
+	}
+	return b.defaults(o)
+}
+
+func (b *builtin) Render(o *Output) (map[string]string, error) {
+	return b.render(o)
+}
+
+// Validate checks parameters which don't depend on rendering. Others, such as parameters removed from the
+// plugin configuration, are checked while rendering.
+func (b *builtin) Validate(params map[string]string) error {
+	if b.validate == nil {
+		return nil
+	}
+	return b.validate(params)
+}
+
+// objectPath defaults path of objects in object stores other than S3
+func objectPath(o *Output) map[string]string {
+	return map[string]string{"path": defaultObjectPath}
+}
+
+// syslogPort defaults port of syslog outputs, 6514 with TLS and 514 otherwise
+func syslogPort(o *Output) map[string]string {
+	for _, p := range o.obj.Spec.Params {
+		if strings.ToLower(p.Name) == "protocol" && p.Value == "tls" {
+			return map[string]string{"port": "6514"}
+		}
+	}
+	return map[string]string{"port": "514"}
+}
+
+func init() {
+	Register("stdout", &builtin{render: (*Output).getStdoutParams})
+	Register("file", &builtin{
+		render: (*Output).getFileParams,
+		defaults: func(o *Output) map[string]string {
+			return map[string]string{"path": fmt.Sprintf("/fluentd/log/%s", o.obj.Name)}
+		},
+	})
+	Register("elasticsearch", &builtin{render: (*Output).getEsParams})
+	Register("opensearch", &builtin{render: (*Output).getOpenSearchParams})
+	Register("loki", &builtin{required: []string{"url"}, render: (*Output).getLokiParams,
+		validate: validateLokiParams})
+	Register("s3", &builtin{required: []string{"s3_bucket", "s3_region"}, render: (*Output).getS3Params})
+	Register("gcs", &builtin{required: []string{"bucket"}, defaults: objectPath, render: (*Output).getGCSParams})
+	Register("azureblob", &builtin{required: []string{"azure_container"}, defaults: objectPath,
+		render: (*Output).getAzureBlobParams})
+	Register("kafka", &builtin{required: []string{"brokers"}, render: (*Output).getKafkaParams})
+	Register("splunk", &builtin{required: []string{"hec_token"}, render: (*Output).getSplunkParams})
+	Register("http", &builtin{required: []string{"endpoint"}, render: (*Output).getHTTPParams,
+		validate: validateHTTPParams})
+	Register("syslog", &builtin{
+		required: []string{"host"},
+		defaults: syslogPort,
+		render:   (*Output).getSyslogParams,
+	})
+	Register("forward", &builtin{render: (*Output).getForwardParams})
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// unregister removes output type typ, so that tests can register types again
+func unregister(typ string) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+
+	delete(renderers, strings.ToLower(typ))
+}
+
+// siteRenderer renders a site-specific output type, as a fork would register it
+type siteRenderer struct{}
+
+func (siteRenderer) Required() []string {
+	return []string{"endpoint"}
+}
+
+func (siteRenderer) Defaults(o *Output) map[string]string {
+	return map[string]string{"region": "local"}
+}
+
+func (siteRenderer) Render(o *Output) (map[string]string, error) {
+	params, err := o.Params()
+	if err != nil {
+		return nil, err
+	}
+	params["@type"] = "site"
+	o.AddSection("buffer", "tag", map[string]string{"flush_interval": "1s"})
+	return params, nil
+}
+
+func (siteRenderer) Validate(params map[string]string) error {
+	if params["region"] == "nowhere" {
+		return fmt.Errorf("region nowhere is not supported")
+	}
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	Register("Site", siteRenderer{})
+	t.Cleanup(func() { unregister("site") })
+	assert.Contains(t, Types(), "site")
+	assert.Panics(t, func() { Register("site", siteRenderer{}) })
+
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake"},
+		Spec: v1alpha1.OutputSpec{
+			Type:   "site",
+			Params: []v1alpha1.Param{v1alpha1.Param{Name: "endpoint", Value: "site.example.com"}},
+		},
+	}
+	o := NewOutput(fake.NewFakeClient(), &obj)
+
+	out, err := o.Render()
+	assert.Nil(t, err)
+	assert.Contains(t, string(out), "@type site\n        endpoint site.example.com\n        region local")
+	assert.Contains(t, string(out), "<buffer tag>\n            flush_interval 1s\n        </buffer>")
+
+	// Defaults don't override parameters, which are validated
+	obj.Spec.Params = append(obj.Spec.Params, v1alpha1.Param{Name: "region", Value: "nowhere"})
+	_, err = o.Render()
+	assert.NotNil(t, err)
+
+	obj.Spec.Params = nil
+	_, err = o.Render()
+	assert.EqualError(t, err, "Mandatory site parameter endpoint is missing")
+
+	// Unknown types list supported ones
+	obj.Spec.Type = "unknown"
+	_, err = o.Render()
+	assert.Contains(t, err.Error(), "supported types are azureblob, elasticsearch,")
+}
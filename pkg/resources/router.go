@@ -0,0 +1,54 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Router copies every record to the labels of all outputs. fluentd stops at the first matching
+// <match>, so outputs cannot simply be listed one after another.
+type Router struct {
+	outputs []*Output
+}
+
+// NewRouter returns a new router for given outputs
+func NewRouter(outputs []*Output) *Router {
+	return &Router{
+		outputs: outputs,
+	}
+}
+
+// Render returns byte array representing fluentd configuration of the router
+func (r *Router) Render() ([]byte, error) {
+	var ret bytes.Buffer
+	fmt.Fprintf(&ret, "<match **>")
+	if len(r.outputs) == 0 {
+		fmt.Fprintf(&ret, "\n    @type null")
+		fmt.Fprintf(&ret, "\n</match>")
+		return ret.Bytes(), nil
+	}
+
+	fmt.Fprintf(&ret, "\n    @type copy")
+	for _, o := range r.outputs {
+		fmt.Fprintf(&ret, "\n    <store>")
+		fmt.Fprintf(&ret, "\n        @type relabel")
+		fmt.Fprintf(&ret, "\n        @label %s", o.label())
+		fmt.Fprintf(&ret, "\n    </store>")
+	}
+	fmt.Fprintf(&ret, "\n</match>")
+
+	return ret.Bytes(), nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getStdoutOutput(name string) *Output {
+	obj := v1alpha1.Output{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1alpha1.OutputSpec{
+			Type: "stdout",
+		},
+	}
+
+	return NewOutput(fake.NewFakeClient(), &obj)
+}
+
+func TestEmptyRouter(t *testing.T) {
+	buf, err := NewRouter([]*Output{}).Render()
+	assert.Nil(t, err)
+	assert.Equal(t, "<match **>\n    @type null\n</match>", string(buf))
+}
+
+func TestRouter(t *testing.T) {
+	outputs := []*Output{getStdoutOutput("first"), getStdoutOutput("second")}
+	buf, err := NewRouter(outputs).Render()
+	assert.Nil(t, err)
+
+	cfg := string(buf)
+	assert.Contains(t, cfg, "@type copy")
+	assert.Equal(t, 2, strings.Count(cfg, "@type relabel"))
+	assert.Contains(t, cfg, "@label @output-first")
+	assert.Contains(t, cfg, "@label @output-second")
+}
+
+func TestOutputMatch(t *testing.T) {
+	buf, err := getStdoutOutput("containers").Render()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(buf), "<label @output-containers>\n    <filter **>"))
+	assert.Contains(t, string(buf), "\n    </filter>\n    <match kube.** node.** k8s.events.**>\n        @type stdout")
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// SearchSetup is what the operator installs in the elasticsearch or opensearch cluster of an output, before
+// fluentd writes to it
+type SearchSetup struct {
+	// URL of the cluster
+	URL      string
+	User     string
+	Password string
+	// Insecure skips verification of the cluster certificate
+	Insecure bool
+	// Resources are PUT to the cluster in order, so that a lifecycle policy exists before templates using it
+	Resources []SearchResource
+}
+
+// SearchResource is a document PUT to path of a cluster, such as an index template
+type SearchResource struct {
+	Path string
+	Body []byte
+}
+
+// searchKind describes differences of elasticsearch and opensearch outputs
+type searchKind struct {
+	// plugin is the fluentd output plugin, which is suffixed with _data_stream for data streams
+	plugin string
+	// host is the default host of the cluster
+	host string
+	// policy is the parameter holding lifecycle policy of indices
+	policy string
+	// policyPath is where the policy is installed, by its id
+	policyPath string
+}
+
+var (
+	elasticsearch = searchKind{
+		plugin:     "elasticsearch",
+		host:       "elasticsearch",
+		policy:     "ilm_policy",
+		policyPath: "/_ilm/policy/",
+	}
+	opensearch = searchKind{
+		plugin:     "opensearch",
+		host:       "opensearch",
+		policy:     "ism_policy",
+		policyPath: "/_plugins/_ism/policies/",
+	}
+)
+
+// searchFileParams are JSON documents of elasticsearch and opensearch outputs
+var searchFileParams = []string{"index_template", "ilm_policy", "ism_policy"}
+
+// SearchSetup returns what the operator installs in the cluster of an elasticsearch or opensearch output, or nil
+// if there is nothing to install. It is known once the output is rendered.
+func (o *Output) SearchSetup() *SearchSetup {
+	return o.setup
+}
+
+// getSearchParams returns parameters of elasticsearch and opensearch outputs. Index template and lifecycle
+// policy are installed by fluentd, or by the operator with install_by operator.
+func (o *Output) getSearchParams(kind searchKind) (map[string]string, error) {
+	params, err := o.getParams(searchFileParams...)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	params["@type"] = kind.plugin
+	name := fmt.Sprintf("fluentd-%s", o.obj.Name)
+
+	if _, ok := params["index_name"]; !ok {
+		params["index_name"] = name
+	}
+
+	if v, ok := params["url"]; ok {
+		u, err := url.Parse(v)
+		if err != nil {
+			return map[string]string{}, err
+		}
+		if u.Port() != "" {
+			params["port"] = u.Port()
+		}
+		if u.Hostname() != "" {
+			params["host"] = u.Hostname()
+		}
+		if u.Scheme != "" {
+			params["scheme"] = u.Scheme
+		}
+		delete(params, "url")
+	} else {
+		params["host"] = kind.host
+		params["port"] = "9200"
+		params["scheme"] = "http"
+	}
+
+	// Indices are suffixed by date with logstash_format, e.g. fluentd-<name>-2020.01.31
+	if params["logstash_format"] == "true" {
+		if _, ok := params["logstash_prefix"]; !ok {
+			params["logstash_prefix"] = name
+		}
+	}
+
+	installBy := "fluentd"
+	if v, ok := params["install_by"]; ok {
+		installBy = v
+		delete(params, "install_by")
+	}
+	if installBy != "fluentd" && installBy != "operator" {
+		return map[string]string{}, fmt.Errorf("Parameter install_by %s is not one of fluentd, operator", installBy)
+	}
+
+	for _, p := range []string{elasticsearch.policy, opensearch.policy} {
+		if _, ok := params[p]; ok && p != kind.policy {
+			return map[string]string{}, fmt.Errorf("Parameter %s is not supported by %s", p, kind.plugin)
+		}
+	}
+
+	template, hasTemplate := o.takeFile(params, "index_template")
+	policy, hasPolicy := o.takeFile(params, kind.policy)
+	for p, doc := range map[string][]byte{"index_template": template, kind.policy: policy} {
+		if len(doc) > 0 && !json.Valid(doc) {
+			return map[string]string{}, fmt.Errorf("Parameter %s is not valid JSON", p)
+		}
+	}
+
+	// fluent-plugin-opensearch doesn't manage ISM policies
+	if hasPolicy && kind.policy == opensearch.policy && installBy != "operator" {
+		return map[string]string{}, fmt.Errorf("Parameter %s requires install_by operator", kind.policy)
+	}
+
+	templateName := takeParam(params, "template_name", name)
+	policyID := takeParam(params, kind.policy+"_id", name)
+
+	if _, ok := params["data_stream_name"]; ok {
+		if params["logstash_format"] == "true" {
+			return map[string]string{}, fmt.Errorf("Parameter logstash_format is not supported with data streams")
+		}
+		if hasTemplate && installBy != "operator" {
+			return map[string]string{}, fmt.Errorf("Parameter index_template of data streams requires install_by operator")
+		}
+
+		params["@type"] = kind.plugin + "_data_stream"
+		delete(params, "index_name")
+		if hasTemplate {
+			params["data_stream_template_name"] = templateName
+		}
+		if hasPolicy && kind.policy == elasticsearch.policy {
+			params["data_stream_ilm_name"] = policyID
+			if installBy == "fluentd" {
+				params["data_stream_ilm_policy"] = compactJSON(policy)
+			}
+		}
+	} else {
+		if hasTemplate && installBy == "fluentd" {
+			file := fmt.Sprintf("%s.%s", o.obj.Name, "index_template")
+			o.files[file] = template
+			params["template_name"] = templateName
+			params["template_file"] = path.Join(ConfigDir, file)
+			params["use_legacy_template"] = "false"
+		}
+		if hasPolicy && kind.policy == elasticsearch.policy {
+			params["enable_ilm"] = "true"
+			params["ilm_policy_id"] = policyID
+			if installBy == "fluentd" {
+				params["ilm_policy"] = compactJSON(policy)
+			}
+		}
+	}
+
+	if installBy == "operator" && (hasTemplate || hasPolicy) {
+		setup := &SearchSetup{
+			URL: fmt.Sprintf("%s://%s:%s%s", params["scheme"], params["host"], params["port"],
+				strings.TrimSuffix(params["path"], "/")),
+			Insecure: params["ssl_verify"] == "false",
+		}
+		if setup.User, err = o.getRawParam("user"); err != nil {
+			return map[string]string{}, err
+		}
+		if setup.Password, err = o.getRawParam("password"); err != nil {
+			return map[string]string{}, err
+		}
+
+		if hasPolicy {
+			setup.Resources = append(setup.Resources, SearchResource{Path: kind.policyPath + policyID, Body: policy})
+		}
+		if hasTemplate {
+			setup.Resources = append(setup.Resources, SearchResource{Path: "/_index_template/" + templateName,
+				Body: template})
+		}
+		o.setup = setup
+	}
+
+	return params, nil
+}
+
+// takeFile removes file parameter name of the output, returning its data
+func (o *Output) takeFile(params map[string]string, name string) ([]byte, bool) {
+	if _, ok := params[name]; !ok {
+		return nil, false
+	}
+
+	file := fmt.Sprintf("%s.%s", o.obj.Name, name)
+	data := o.files[file]
+	delete(params, name)
+	delete(o.files, file)
+	return data, true
+}
+
+// takeParam removes parameter name, returning its value or def if it is not set
+func takeParam(params map[string]string, name, def string) string {
+	v, ok := params[name]
+	if !ok {
+		return def
+	}
+	delete(params, name)
+	return v
+}
+
+// getRawParam returns value of parameter name as is, or an empty string if it is not set
+func (o *Output) getRawParam(name string) (string, error) {
+	for _, p := range o.params() {
+		if strings.ToLower(p.Name) == name {
+			v, err := o.getRaw(&p)
+			return string(v), err
+		}
+	}
+	return "", nil
+}
+
+// compactJSON returns valid JSON doc on one line, as fluentd parameters of type hash
+func compactJSON(doc []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, doc); err != nil {
+		return string(doc)
+	}
+	return buf.String()
+}
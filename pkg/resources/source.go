@@ -17,18 +17,24 @@ import (
 	"bytes"
 	"fmt"
 
+	"github.com/platform9/fluentd-operator/pkg/certs"
 	"github.com/platform9/fluentd-operator/pkg/options"
 )
 
+// Hostname fluentd presents to forwarding clients during handshake
+const selfHostname = "fluentd"
+
 // Source represents implementation of fluentd source configuration.
 type Source struct {
-	port int
+	port   int
+	secure bool
 }
 
 // NewSource returns a new source object
-func NewSource() *Source {
+func NewSource(cfg *options.Config) *Source {
 	return &Source{
-		port: *(options.ForwardPort),
+		port:   cfg.ForwardPort,
+		secure: cfg.SecureForward,
 	}
 }
 
@@ -39,6 +45,16 @@ func (s *Source) Render() ([]byte, error) {
 	fmt.Fprintf(&ret, "\n    @type forward")
 	fmt.Fprintf(&ret, "\n    port %d", s.port)
 	fmt.Fprintf(&ret, "\n    bind 0.0.0.0")
+	if s.secure {
+		fmt.Fprintf(&ret, "\n    <transport tls>")
+		fmt.Fprintf(&ret, "\n        cert_path %s/%s", certs.FluentdMountPath, certs.CertKey)
+		fmt.Fprintf(&ret, "\n        private_key_path %s/%s", certs.FluentdMountPath, certs.KeyKey)
+		fmt.Fprintf(&ret, "\n    </transport>")
+		fmt.Fprintf(&ret, "\n    <security>")
+		fmt.Fprintf(&ret, "\n        self_hostname %s", selfHostname)
+		fmt.Fprintf(&ret, "\n        shared_key \"#{ENV['%s']}\"", certs.SharedKeyEnv)
+		fmt.Fprintf(&ret, "\n    </security>")
+	}
 	fmt.Fprintf(&ret, "\n</source>")
 
 	return ret.Bytes(), nil
@@ -17,6 +17,7 @@ import (
 	"encoding/xml"
 	"testing"
 
+	"github.com/platform9/fluentd-operator/pkg/options"
 	"github.com/platform9/fluentd-operator/pkg/resources"
 	"github.com/stretchr/testify/assert"
 )
@@ -27,7 +28,8 @@ type TestSource struct {
 }
 
 func TestSourceRender(t *testing.T) {
-	s := resources.NewSource()
+	t.Parallel()
+	s := resources.NewSource(options.New())
 
 	buf, err := s.Render()
 	assert.Nil(t, err)
@@ -36,3 +38,17 @@ func TestSourceRender(t *testing.T) {
 	assert.Nil(t, xml.Unmarshal(buf, &found))
 
 }
+
+func TestSecureSourceRender(t *testing.T) {
+	t.Parallel()
+	cfg := options.New()
+	cfg.SecureForward = true
+
+	buf, err := resources.NewSource(cfg).Render()
+	assert.Nil(t, err)
+
+	conf := string(buf)
+	assert.Contains(t, conf, "<transport tls>\n        cert_path /fluentd/tls/tls.crt")
+	assert.Contains(t, conf, "private_key_path /fluentd/tls/tls.key")
+	assert.Contains(t, conf, "shared_key \"#{ENV['FLUENT_FORWARD_SHARED_KEY']}\"")
+}
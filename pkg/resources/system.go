@@ -26,9 +26,9 @@ type System struct {
 }
 
 // NewSystem returns a new System object
-func NewSystem() *System {
+func NewSystem(cfg *options.Config) *System {
 	return &System{
-		port: *(options.ReloadPort),
+		port: cfg.ReloadPort,
 	}
 }
 
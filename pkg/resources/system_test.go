@@ -30,7 +30,9 @@ type TestSystem struct {
 }
 
 func TestSystemRender(t *testing.T) {
-	s := resources.NewSystem()
+	cfg := options.New()
+	cfg.ReloadPort = 45551
+	s := resources.NewSystem(cfg)
 
 	buf, err := s.Render()
 	assert.Nil(t, err)
@@ -39,6 +41,6 @@ func TestSystemRender(t *testing.T) {
 	assert.Nil(t, xml.Unmarshal(buf, &found))
 
 	rpcVal := strings.Trim(found.Endpoint, " \n")
-	assert.Equal(t, fmt.Sprintf("rpc_endpoint 0.0.0.0:%d", *(options.ReloadPort)), rpcVal)
+	assert.Equal(t, fmt.Sprintf("rpc_endpoint 0.0.0.0:%d", cfg.ReloadPort), rpcVal)
 
 }
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package search installs index templates and lifecycle policies of elasticsearch and opensearch outputs, which
+// the operator manages rather than fluentd.
+package search
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/platform9/fluentd-operator/pkg/resources"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Install PUTs resources of setup to its cluster. Resources which exist and can't be updated in place, such as
+// ISM policies of opensearch, are left as they are.
+func Install(setup *resources.SearchSetup) error {
+	c := &http.Client{Timeout: defaultTimeout}
+	if setup.Insecure {
+		c.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	for _, r := range setup.Resources {
+		req, err := http.NewRequest(http.MethodPut, setup.URL+r.Path, bytes.NewReader(r.Body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if setup.User != "" {
+			req.SetBasicAuth(setup.User, setup.Password)
+		}
+
+		if err := do(c, req); err != nil {
+			return fmt.Errorf("installing %s: %v", r.Path, err)
+		}
+	}
+	return nil
+}
+
+func do(c *http.Client, req *http.Request) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict || (resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: 512})
+	return fmt.Errorf("%s: %s", resp.Status, body)
+}
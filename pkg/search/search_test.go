@@ -0,0 +1,69 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/platform9/fluentd-operator/pkg/resources"
+	"github.com/platform9/fluentd-operator/pkg/search"
+	"github.com/stretchr/testify/assert"
+)
+
+// stub records documents PUT to it, by path, and answers with status of a path
+type stub struct {
+	docs   map[string]string
+	status map[string]int
+}
+
+func (s *stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, _ := r.BasicAuth(); r.Method != http.MethodPut || user != "elastic" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	s.docs[r.URL.Path] = string(body)
+	if code, ok := s.status[r.URL.Path]; ok {
+		w.WriteHeader(code)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	s := &stub{docs: map[string]string{}, status: map[string]int{"/_plugins/_ism/policies/exists": http.StatusConflict}}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	setup := &resources.SearchSetup{
+		URL:      srv.URL,
+		User:     "elastic",
+		Password: "secret",
+		Resources: []resources.SearchResource{
+			{Path: "/_plugins/_ism/policies/exists", Body: []byte(`{"policy":{}}`)},
+			{Path: "/_index_template/logs", Body: []byte(`{"index_patterns":["logs-*"]}`)},
+		},
+	}
+	assert.Nil(t, search.Install(setup))
+	assert.Equal(t, `{"index_patterns":["logs-*"]}`, s.docs["/_index_template/logs"])
+
+	// Errors of the cluster are returned
+	s.status["/_index_template/logs"] = http.StatusBadRequest
+	assert.NotNil(t, search.Install(setup))
+
+	setup.Password = "wrong"
+	assert.NotNil(t, search.Install(setup))
+}
@@ -14,17 +14,47 @@ limitations under the License.
 package utils
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 
-	"github.com/platform9/fluentd-operator/pkg/options"
+	"github.com/platform9/fluentd-operator/etc/conf"
+	"github.com/presslabs/controller-util/syncer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
-// GetCfgMapData returns a map of filename==>contents
-func GetCfgMapData(subdir string) (map[string][]byte, error) {
-	subDir := fmt.Sprintf("%s/%s", *(options.CfgDir), subdir)
+const (
+	// OverrideAnnotation set to "true" on a config map managed by operator keeps its data as edited
+	OverrideAnnotation = "logging.pf9.io/override"
+	// checksumAnnotation is the checksum of data operator last wrote to a config map
+	checksumAnnotation = "logging.pf9.io/checksum"
+	// DriftReason is the reason of events emitted when a config map edited outside of operator is restored
+	DriftReason = "ConfigMapDrift"
+)
+
+// getCfgFS returns configuration of a component from cfgDir, or the embedded defaults if cfgDir is empty
+func getCfgFS(cfgDir, subdir string) (fs.FS, error) {
+	if len(cfgDir) > 0 {
+		return os.DirFS(filepath.Join(cfgDir, subdir)), nil
+	}
+	return fs.Sub(conf.FS, subdir)
+}
+
+// GetCfgMapData returns a map of filename==>contents of files in subdir of configuration directory cfgDir.
+// Embedded defaults are used when cfgDir is empty.
+func GetCfgMapData(cfgDir, subdir string) (map[string][]byte, error) {
 	data := map[string][]byte{}
-	files, err := ioutil.ReadDir(subDir)
+	fsys, err := getCfgFS(cfgDir, subdir)
+	if err != nil {
+		return data, err
+	}
+
+	files, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return data, err
 	}
@@ -33,7 +63,7 @@ func GetCfgMapData(subdir string) (map[string][]byte, error) {
 		if file.IsDir() {
 			continue
 		}
-		content, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", subDir, file.Name()))
+		content, err := fs.ReadFile(fsys, file.Name())
 		if err != nil {
 			return data, err
 		}
@@ -43,3 +73,85 @@ func GetCfgMapData(subdir string) (map[string][]byte, error) {
 
 	return data, nil
 }
+
+// ValidateCfgDir verifies that configuration directory cfgDir overriding embedded defaults has every file
+// of the defaults. An empty cfgDir is valid.
+func ValidateCfgDir(cfgDir string) error {
+	if len(cfgDir) == 0 {
+		return nil
+	}
+
+	return fs.WalkDir(conf.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(cfgDir, path)); err != nil {
+			return fmt.Errorf("invalid config directory %s: %v", cfgDir, err)
+		}
+		return nil
+	})
+}
+
+// DumpDefaultCfg writes embedded default configuration into dir, which can then be edited and passed as
+// configuration directory
+func DumpDefaultCfg(dir string) error {
+	return fs.WalkDir(conf.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, path)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		content, err := fs.ReadFile(conf.FS, path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, content, 0644)
+	})
+}
+
+// cfgMapChecksum returns checksum of data of config map cm
+func cfgMapChecksum(cm *corev1.ConfigMap) string {
+	keys := []string{}
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	for k := range cm.BinaryData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s\x00%s%s\x00", k, cm.Data[k], cm.BinaryData[k])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// SyncCfgMapData makes data the only content of config map cm, emitting a warning event with e if cm was edited
+// since operator last wrote it. Config maps annotated with OverrideAnnotation are left as is, in which case
+// syncer.ErrIgnore is returned.
+func SyncCfgMapData(e record.EventRecorder, cm *corev1.ConfigMap, data map[string][]byte) error {
+	annotations := cm.GetAnnotations()
+	if annotations[OverrideAnnotation] == "true" {
+		return syncer.ErrIgnore
+	}
+
+	if last, ok := annotations[checksumAnnotation]; ok && last != cfgMapChecksum(cm) {
+		e.Eventf(cm, corev1.EventTypeWarning, DriftReason,
+			"ConfigMap %s/%s was edited outside of operator, restoring it. Annotate it with %s=true to keep edits.",
+			cm.Namespace, cm.Name, OverrideAnnotation)
+	}
+
+	cm.Data = nil
+	cm.BinaryData = data
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[checksumAnnotation] = cfgMapChecksum(cm)
+	cm.SetAnnotations(annotations)
+	return nil
+}
@@ -14,18 +14,18 @@ limitations under the License.
 package utils_test
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/platform9/fluentd-operator/pkg/utils"
+	"github.com/presslabs/controller-util/syncer"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
-func TestMain(t *testing.T) {
-	err := os.Chdir("../../")
-	assert.Nil(t, err)
-}
-
 func getKeys(d map[string][]byte) []string {
 	keys := []string{}
 
@@ -36,19 +36,52 @@ func getKeys(d map[string][]byte) []string {
 	return keys
 }
 func TestGetConfigForFluentbit(t *testing.T) {
-	d, err := utils.GetCfgMapData("fluent-bit")
+	d, err := utils.GetCfgMapData("", "fluent-bit")
 	assert.Nil(t, err)
-	assert.Equal(t, 6, len(d))
+	assert.Equal(t, 4, len(d))
 	keys := getKeys(d)
-	assert.ElementsMatch(t, []string{"filter.conf", "fluent-bit.conf", "input.conf", "output.conf",
-		"parsers.conf", "null.conf"}, keys)
+	assert.ElementsMatch(t, []string{"filter.conf", "fluent-bit.conf", "parsers.conf", "null.conf"}, keys)
 }
 
-func TestGetConfigForFluentd(t *testing.T) {
-	d, err := utils.GetCfgMapData("fluentd")
+func TestOverrideConfig(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, utils.DumpDefaultCfg(dir))
+	assert.Nil(t, utils.ValidateCfgDir(dir))
+
+	custom := filepath.Join(dir, "fluent-bit", "filter.conf")
+	assert.Nil(t, ioutil.WriteFile(custom, []byte("[FILTER]\n"), 0644))
+	d, err := utils.GetCfgMapData(dir, "fluent-bit")
 	assert.Nil(t, err)
-	assert.Equal(t, 1, len(d))
+	assert.Equal(t, 4, len(d))
+	assert.Equal(t, "[FILTER]\n", string(d["filter.conf"]))
 
-	keys := getKeys(d)
-	assert.ElementsMatch(t, []string{"fluent.conf"}, keys)
+	assert.Nil(t, os.Remove(custom))
+	assert.NotNil(t, utils.ValidateCfgDir(dir))
+	assert.NotNil(t, utils.ValidateCfgDir(filepath.Join(dir, "missing")))
+	assert.Nil(t, utils.ValidateCfgDir(""))
+}
+
+func TestSyncCfgMapData(t *testing.T) {
+	e := record.NewFakeRecorder(8)
+	cm := &corev1.ConfigMap{}
+	data := map[string][]byte{"fluent.conf": []byte("desired")}
+
+	assert.Nil(t, utils.SyncCfgMapData(e, cm, data))
+	assert.Nil(t, utils.SyncCfgMapData(e, cm, data))
+	assert.Empty(t, e.Events)
+
+	// Hand edits are reported and reverted
+	cm.Data = map[string]string{"extra.conf": "edited"}
+	cm.BinaryData["fluent.conf"] = []byte("edited")
+	assert.Nil(t, utils.SyncCfgMapData(e, cm, data))
+	assert.Contains(t, <-e.Events, utils.DriftReason)
+	assert.Empty(t, cm.Data)
+	assert.Equal(t, data, cm.BinaryData)
+
+	// Overrides are kept
+	cm.Annotations[utils.OverrideAnnotation] = "true"
+	cm.BinaryData = map[string][]byte{"fluent.conf": []byte("edited")}
+	assert.Equal(t, syncer.ErrIgnore, utils.SyncCfgMapData(e, cm, data))
+	assert.Equal(t, "edited", string(cm.BinaryData["fluent.conf"]))
+	assert.Empty(t, e.Events)
 }
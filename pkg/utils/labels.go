@@ -13,6 +13,16 @@ limitations under the License.
 
 package utils
 
+import (
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// CreatedBy labels every object created by the operator
+var CreatedBy = map[string]string{
+	"created_by": "fluentd-operator",
+}
+
 // CheckSubset returns true if map "a" is subset of map "b"
 func CheckSubset(a, b map[string]string) bool {
 	for k, v := range a {
@@ -25,3 +35,22 @@ func CheckSubset(a, b map[string]string) bool {
 
 	return true
 }
+
+// CreatedByOperator filters events of objects labeled with CreatedBy. An update passes if either version is
+// labeled, so that operator restores labels removed from its objects.
+func CreatedByOperator() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return CheckSubset(CreatedBy, e.Meta.GetLabels())
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return CheckSubset(CreatedBy, e.MetaOld.GetLabels()) || CheckSubset(CreatedBy, e.MetaNew.GetLabels())
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return CheckSubset(CreatedBy, e.Meta.GetLabels())
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return CheckSubset(CreatedBy, e.Meta.GetLabels())
+		},
+	}
+}
@@ -18,6 +18,9 @@ import (
 
 	"github.com/platform9/fluentd-operator/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 func TestWithSameDicts(t *testing.T) {
@@ -43,3 +46,17 @@ func TestWithDiffValues(t *testing.T) {
 	var b = map[string]string{"1": "", "2": "", "3": "false"}
 	assert.False(t, utils.CheckSubset(a, b))
 }
+
+func TestCreatedByOperator(t *testing.T) {
+	p := utils.CreatedByOperator()
+	owned := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "fluentd-config", Labels: utils.CreatedBy}}
+	other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{"app": "x"}}}
+
+	assert.True(t, p.Create(event.CreateEvent{Meta: owned, Object: owned}))
+	assert.False(t, p.Create(event.CreateEvent{Meta: other, Object: other}))
+	assert.False(t, p.Delete(event.DeleteEvent{Meta: other, Object: other}))
+
+	// Removing operator labels is an interesting update
+	assert.True(t, p.Update(event.UpdateEvent{MetaOld: owned, ObjectOld: owned, MetaNew: other, ObjectNew: other}))
+	assert.False(t, p.Update(event.UpdateEvent{MetaOld: other, ObjectOld: other, MetaNew: other, ObjectNew: other}))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2019 Platform9 Systems, Inc.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+
+	"github.com/platform9/fluentd-operator/pkg/apis/logging/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EnsureLoggingConfig returns the singleton LoggingConfig, creating an empty one if it does not exist. It owns
+// every object operator manages, so that they are garbage collected along with it, e.g. when operator CRDs
+// are uninstalled.
+func EnsureLoggingConfig(c client.Client) (*v1alpha1.LoggingConfig, error) {
+	cfg := &v1alpha1.LoggingConfig{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: v1alpha1.LoggingConfigName}, cfg)
+	if !errors.IsNotFound(err) {
+		return cfg, err
+	}
+
+	cfg = &v1alpha1.LoggingConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   v1alpha1.LoggingConfigName,
+			Labels: CreatedBy,
+		},
+	}
+	return cfg, c.Create(context.TODO(), cfg)
+}
+
+// ApplyComponentSpec customizes pod spec built by operator, whose first container runs the fluent component
+func ApplyComponentSpec(pod *corev1.PodSpec, spec v1alpha1.ComponentSpec) {
+	container := &pod.Containers[0]
+
+	if len(spec.Image) > 0 {
+		container.Image = spec.Image
+	}
+
+	if spec.Resources != nil {
+		container.Resources = *spec.Resources
+	}
+
+	if len(spec.Tolerations) > 0 {
+		pod.Tolerations = spec.Tolerations
+	}
+
+	pod.NodeSelector = spec.NodeSelector
+	pod.PriorityClassName = spec.PriorityClassName
+
+	container.Env = append(container.Env, spec.Env...)
+	container.VolumeMounts = append(container.VolumeMounts, spec.VolumeMounts...)
+	pod.Volumes = append(pod.Volumes, spec.Volumes...)
+}